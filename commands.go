@@ -0,0 +1,590 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/sonkibon/go-samples/pubsub"
+)
+
+// resource is a queue, topic or subscription in the output of the commands.
+type resource struct {
+	Name string `json:"name,omitempty"`
+	Arn  string `json:"arn"`
+	Url  string `json:"url,omitempty"`
+}
+
+// print writes the resource.
+func (r resource) print(out *printer) error {
+	return out.print(r, []string{"NAME", "ARN", "URL"}, [][]string{{r.Name, r.Arn, r.Url}})
+}
+
+// parse parses the flags of a subcommand and checks that the required flags are set.
+func parse(fs *flag.FlagSet, args []string, required ...string) error {
+	if err := fs.Parse(args); err != nil {
+		return fmt.Errorf("fs.Parse: %w", err)
+	}
+	for _, name := range required {
+		if fs.Lookup(name).Value.String() == "" {
+			return fmt.Errorf("-%s is required", name)
+		}
+	}
+
+	return nil
+}
+
+// createQueue creates a queue, optionally with a dead-letter queue.
+func createQueue(ctx context.Context, e *env, args []string) error {
+	fs := flag.NewFlagSet("create-queue", flag.ExitOnError)
+	name := fs.String("name", "", "queue name")
+	dlq := fs.String("dlq", "", "ARN of the dead-letter queue")
+	maxReceive := fs.Int64("max-receive", 5, "receive count before a message is moved to the dead-letter queue")
+	attrs := keyValueFlag{}
+	fs.Var(attrs, "attr", "queue attribute as KEY=VALUE, repeatable")
+	if err := parse(fs, args, "name"); err != nil {
+		return err
+	}
+
+	var (
+		queue *pubsub.Queue
+		err   error
+	)
+	if *dlq != "" {
+		dlqQueue, err := e.client.NewQueueContext(ctx, *dlq)
+		if err != nil {
+			return fmt.Errorf("e.client.NewQueueContext: %w", err)
+		}
+		queue, err = e.client.CreateQueueWithDLQContext(ctx, *name, *dlqQueue, *maxReceive, attrs.opts())
+		if err != nil {
+			return fmt.Errorf("e.client.CreateQueueWithDLQContext: %w", err)
+		}
+	} else {
+		queue, err = e.client.CreateQueueContext(ctx, *name, attrs.opts())
+		if err != nil {
+			return fmt.Errorf("e.client.CreateQueueContext: %w", err)
+		}
+	}
+
+	return resource{Name: queue.Name(), Arn: queue.Arn(), Url: queue.Url()}.print(e.out)
+}
+
+// deleteQueue deletes a queue.
+func deleteQueue(ctx context.Context, e *env, args []string) error {
+	fs := flag.NewFlagSet("delete-queue", flag.ExitOnError)
+	queueArn := fs.String("queue", "", "queue ARN")
+	force := fs.Bool("force", false, "delete the queue even if it has messages")
+	if err := parse(fs, args, "queue"); err != nil {
+		return err
+	}
+
+	queue, err := e.client.NewQueueContext(ctx, *queueArn)
+	if err != nil {
+		return fmt.Errorf("e.client.NewQueueContext: %w", err)
+	}
+	if err := queue.Delete(ctx, *force); err != nil {
+		return fmt.Errorf("queue.Delete: %w", err)
+	}
+
+	return resource{Name: queue.Name(), Arn: queue.Arn(), Url: queue.Url()}.print(e.out)
+}
+
+// listQueues lists the queues.
+func listQueues(ctx context.Context, e *env, args []string) error {
+	fs := flag.NewFlagSet("list-queues", flag.ExitOnError)
+	prefix := fs.String("prefix", "", "queue name prefix")
+	if err := parse(fs, args); err != nil {
+		return err
+	}
+
+	var (
+		queues []resource
+		rows   [][]string
+	)
+	it := e.client.ListQueues(*prefix)
+	for it.Next(ctx) {
+		queue := it.Value()
+		queues = append(queues, resource{Name: queue.Name(), Arn: queue.Arn(), Url: queue.Url()})
+		rows = append(rows, []string{queue.Name(), queue.Arn(), queue.Url()})
+	}
+	if err := it.Err(); err != nil {
+		return fmt.Errorf("e.client.ListQueues: %w", err)
+	}
+
+	return e.out.print(queues, []string{"NAME", "ARN", "URL"}, rows)
+}
+
+// createTopic creates a topic.
+func createTopic(ctx context.Context, e *env, args []string) error {
+	fs := flag.NewFlagSet("create-topic", flag.ExitOnError)
+	name := fs.String("name", "", "topic name")
+	attrs := keyValueFlag{}
+	fs.Var(attrs, "attr", "topic attribute as KEY=VALUE, repeatable")
+	if err := parse(fs, args, "name"); err != nil {
+		return err
+	}
+
+	topic, err := e.client.CreateTopicContext(ctx, *name, attrs.opts())
+	if err != nil {
+		return fmt.Errorf("e.client.CreateTopicContext: %w", err)
+	}
+
+	return resource{Name: topic.Name(), Arn: topic.Arn()}.print(e.out)
+}
+
+// deleteTopic deletes a topic, optionally with its subscriptions, queues and dead-letter queues.
+func deleteTopic(ctx context.Context, e *env, args []string) error {
+	fs := flag.NewFlagSet("delete-topic", flag.ExitOnError)
+	topicArn := fs.String("topic", "", "topic ARN")
+	cascade := fs.Bool("cascade", false, "unsubscribe the SQS subscriptions before deleting the topic")
+	deleteQueues := fs.Bool("delete-queues", false, "with -cascade, delete the subscribed queues")
+	deleteDLQs := fs.Bool("delete-dlqs", false, "with -delete-queues, delete the dead-letter queues of the deleted queues")
+	force := fs.Bool("force", false, "delete queues even if they have messages")
+	if err := parse(fs, args, "topic"); err != nil {
+		return err
+	}
+
+	topic, err := e.client.NewTopicContext(ctx, *topicArn)
+	if err != nil {
+		return fmt.Errorf("e.client.NewTopicContext: %w", err)
+	}
+	if !*cascade {
+		if err := topic.Delete(ctx); err != nil {
+			return fmt.Errorf("topic.Delete: %w", err)
+		}
+		return resource{Name: topic.Name(), Arn: topic.Arn()}.print(e.out)
+	}
+
+	result, err := topic.Teardown(ctx, pubsub.TeardownOptions{
+		DeleteQueues:           *deleteQueues,
+		DeleteDeadLetterQueues: *deleteDLQs,
+		Force:                  *force,
+	})
+	if result != nil {
+		var rows [][]string
+		for _, arn := range result.Unsubscribed {
+			rows = append(rows, []string{"unsubscribed", arn})
+		}
+		for _, arn := range result.DeletedQueues {
+			rows = append(rows, []string{"deleted", arn})
+		}
+		for _, arn := range result.KeptQueues {
+			rows = append(rows, []string{"kept", arn})
+		}
+		if err == nil {
+			rows = append(rows, []string{"deleted", result.Topic})
+		}
+		if perr := e.out.print(result, []string{"ACTION", "ARN"}, rows); perr != nil {
+			return perr
+		}
+	}
+	if err != nil {
+		return fmt.Errorf("topic.Teardown: %w", err)
+	}
+
+	return nil
+}
+
+// listTopics lists the topics.
+func listTopics(ctx context.Context, e *env, args []string) error {
+	fs := flag.NewFlagSet("list-topics", flag.ExitOnError)
+	if err := parse(fs, args); err != nil {
+		return err
+	}
+
+	var (
+		topics []resource
+		rows   [][]string
+	)
+	it := e.client.ListTopics()
+	for it.Next(ctx) {
+		topic := it.Value()
+		topics = append(topics, resource{Name: topic.Name(), Arn: topic.Arn()})
+		rows = append(rows, []string{topic.Name(), topic.Arn()})
+	}
+	if err := it.Err(); err != nil {
+		return fmt.Errorf("e.client.ListTopics: %w", err)
+	}
+
+	return e.out.print(topics, []string{"NAME", "ARN"}, rows)
+}
+
+// listSubscriptions lists the subscriptions of a topic, or of every topic.
+func listSubscriptions(ctx context.Context, e *env, args []string) error {
+	fs := flag.NewFlagSet("list-subscriptions", flag.ExitOnError)
+	topicArn := fs.String("topic", "", "topic ARN")
+	if err := parse(fs, args); err != nil {
+		return err
+	}
+
+	it := e.client.ListSubscriptions()
+	if *topicArn != "" {
+		it = e.client.ListSubscriptionsByTopic(*topicArn)
+	}
+	subscriptions, err := it.All(ctx)
+	if err != nil {
+		return fmt.Errorf("it.All: %w", err)
+	}
+	rows := make([][]string, 0, len(subscriptions))
+	for _, s := range subscriptions {
+		rows = append(rows, []string{s.SubscriptionArn, s.TopicArn, s.Protocol, s.Endpoint})
+	}
+
+	return e.out.print(subscriptions, []string{"ARN", "TOPIC", "PROTOCOL", "ENDPOINT"}, rows)
+}
+
+// subscribe subscribes a queue to a topic.
+func subscribe(ctx context.Context, e *env, args []string) error {
+	fs := flag.NewFlagSet("subscribe", flag.ExitOnError)
+	topicArn := fs.String("topic", "", "topic ARN")
+	queueArn := fs.String("queue", "", "queue ARN")
+	attrs := keyValueFlag{}
+	fs.Var(attrs, "attr", "subscription attribute as KEY=VALUE, repeatable")
+	grant := fs.Bool("grant", false, "allow the topic to send messages to the queue in the queue policy")
+	if err := parse(fs, args, "topic", "queue"); err != nil {
+		return err
+	}
+
+	topic, err := e.client.NewTopicContext(ctx, *topicArn)
+	if err != nil {
+		return fmt.Errorf("e.client.NewTopicContext: %w", err)
+	}
+	queue, err := e.client.NewQueueContext(ctx, *queueArn)
+	if err != nil {
+		return fmt.Errorf("e.client.NewQueueContext: %w", err)
+	}
+	subscription, err := e.client.CreateSubscriptionWithOptionsContext(ctx, topic, queue, pubsub.SubscriptionOptions{
+		Attributes:       attrs,
+		GrantQueueAccess: *grant,
+	})
+	if err != nil {
+		return fmt.Errorf("e.client.CreateSubscriptionWithOptionsContext: %w", err)
+	}
+
+	return resource{Arn: subscription.Arn()}.print(e.out)
+}
+
+// unsubscribe deletes a subscription.
+func unsubscribe(ctx context.Context, e *env, args []string) error {
+	fs := flag.NewFlagSet("unsubscribe", flag.ExitOnError)
+	subscriptionArn := fs.String("subscription", "", "subscription ARN")
+	if err := parse(fs, args, "subscription"); err != nil {
+		return err
+	}
+
+	subscription, err := e.client.NewSubscriptionContext(ctx, *subscriptionArn)
+	if err != nil {
+		return fmt.Errorf("e.client.NewSubscriptionContext: %w", err)
+	}
+	if err := subscription.Unsubscribe(ctx); err != nil {
+		return fmt.Errorf("subscription.Unsubscribe: %w", err)
+	}
+
+	return resource{Arn: subscription.Arn()}.print(e.out)
+}
+
+// send sends a message to a queue.
+func send(ctx context.Context, e *env, args []string) error {
+	fs := flag.NewFlagSet("send", flag.ExitOnError)
+	queueArn := fs.String("queue", "", "queue ARN")
+	body := fs.String("body", "", "message body")
+	attrs := keyValueFlag{}
+	fs.Var(attrs, "attr", "String message attribute as KEY=VALUE, repeatable")
+	if err := parse(fs, args, "queue", "body"); err != nil {
+		return err
+	}
+
+	queue, err := e.client.NewQueueContext(ctx, *queueArn)
+	if err != nil {
+		return fmt.Errorf("e.client.NewQueueContext: %w", err)
+	}
+	attributes, err := attrs.attributes().SQS()
+	if err != nil {
+		return fmt.Errorf("attrs.attributes().SQS: %w", err)
+	}
+
+	return queue.Send(ctx, *body, attributes)
+}
+
+// publish publishes a message to a topic.
+func publish(ctx context.Context, e *env, args []string) error {
+	fs := flag.NewFlagSet("publish", flag.ExitOnError)
+	topicArn := fs.String("topic", "", "topic ARN")
+	message := fs.String("message", "", "message")
+	attrs := keyValueFlag{}
+	fs.Var(attrs, "attr", "String message attribute as KEY=VALUE, repeatable")
+	if err := parse(fs, args, "topic", "message"); err != nil {
+		return err
+	}
+
+	topic, err := e.client.NewTopicContext(ctx, *topicArn)
+	if err != nil {
+		return fmt.Errorf("e.client.NewTopicContext: %w", err)
+	}
+	attributes, err := attrs.attributes().SNS()
+	if err != nil {
+		return fmt.Errorf("attrs.attributes().SNS: %w", err)
+	}
+
+	return topic.Publish(ctx, *message, attributes)
+}
+
+// receiveFlags parses the flags shared by receive and peek and returns the received messages.
+func receiveFlags(ctx context.Context, e *env, fs *flag.FlagSet, args []string) (*pubsub.Queue, []*pubsub.Message, error) {
+	queueArn := fs.String("queue", "", "queue ARN")
+	maxMessages := fs.Int("max", 10, "maximum number of messages, 1 to 10")
+	wait := fs.Int("wait", 0, "seconds to wait for messages, 0 to 20")
+	if err := parse(fs, args, "queue"); err != nil {
+		return nil, nil, err
+	}
+
+	queue, err := e.client.NewQueueContext(ctx, *queueArn)
+	if err != nil {
+		return nil, nil, fmt.Errorf("e.client.NewQueueContext: %w", err)
+	}
+	messages, err := queue.Receive(ctx, int32(*maxMessages), int32(*wait))
+	if err != nil {
+		return nil, nil, fmt.Errorf("queue.Receive: %w", err)
+	}
+
+	return queue, messages, nil
+}
+
+// printMessages writes the messages.
+func printMessages(out *printer, messages []*pubsub.Message) error {
+	rows := make([][]string, 0, len(messages))
+	for _, m := range messages {
+		rows = append(rows, []string{m.ID, strconv.Itoa(m.ReceiveCount), m.SentTimestamp.Format(time.RFC3339), m.Body})
+	}
+
+	return out.print(messages, []string{"ID", "RECEIVE COUNT", "SENT", "BODY"}, rows)
+}
+
+// receive receives messages and deletes them with -ack.
+func receive(ctx context.Context, e *env, args []string) error {
+	fs := flag.NewFlagSet("receive", flag.ExitOnError)
+	ack := fs.Bool("ack", false, "delete the received messages")
+	queue, messages, err := receiveFlags(ctx, e, fs, args)
+	if err != nil {
+		return err
+	}
+
+	var errs []error
+	if *ack {
+		for _, m := range messages {
+			if err := queue.Ack(ctx, m); err != nil {
+				errs = append(errs, err)
+			}
+		}
+	}
+	// The messages are printed even if some acks failed, since the others are already deleted.
+	if err := printMessages(e.out, messages); err != nil {
+		return err
+	}
+	if err := errors.Join(errs...); err != nil {
+		return fmt.Errorf("queue.Ack: %w", err)
+	}
+
+	return nil
+}
+
+// peek receives messages and makes them visible again. Receiving increments the receive count of the messages,
+// so repeated peeks move them to the dead-letter queue of a queue with a RedrivePolicy.
+func peek(ctx context.Context, e *env, args []string) error {
+	fs := flag.NewFlagSet("peek", flag.ExitOnError)
+	queue, messages, err := receiveFlags(ctx, e, fs, args)
+	if err != nil {
+		return err
+	}
+
+	var errs []error
+	for _, m := range messages {
+		if err := queue.Release(ctx, m); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	if err := printMessages(e.out, messages); err != nil {
+		return err
+	}
+	if err := errors.Join(errs...); err != nil {
+		return fmt.Errorf("queue.Release: %w", err)
+	}
+
+	return nil
+}
+
+// purge deletes every message in a queue.
+func purge(ctx context.Context, e *env, args []string) error {
+	fs := flag.NewFlagSet("purge", flag.ExitOnError)
+	queueArn := fs.String("queue", "", "queue ARN")
+	if err := parse(fs, args, "queue"); err != nil {
+		return err
+	}
+
+	queue, err := e.client.NewQueueContext(ctx, *queueArn)
+	if err != nil {
+		return fmt.Errorf("e.client.NewQueueContext: %w", err)
+	}
+
+	return queue.Purge(ctx)
+}
+
+// redrive moves messages from a dead-letter queue to their source queue or to another queue.
+func redrive(ctx context.Context, e *env, args []string) error {
+	fs := flag.NewFlagSet("redrive", flag.ExitOnError)
+	dlqArn := fs.String("dlq", "", "ARN of the dead-letter queue to redrive")
+	toArn := fs.String("to", "", "ARN of the destination queue (default: the source queue of each message)")
+	rate := fs.Float64("rate", 0, "maximum messages moved per second (0: no limit)")
+	limit := fs.Int("limit", 0, "maximum messages moved (0: no limit)")
+	attr := fs.String("attr", "", "move only messages with the string attribute, as NAME=VALUE")
+	body := fs.String("body", "", "move only messages whose body matches the regular expression")
+	dryRun := fs.Bool("dry-run", false, "print the messages that would be moved without moving them")
+	if err := parse(fs, args, "dlq"); err != nil {
+		return err
+	}
+
+	dlq, err := e.client.NewQueueContext(ctx, *dlqArn)
+	if err != nil {
+		return fmt.Errorf("e.client.NewQueueContext: %w", err)
+	}
+	opts := pubsub.RedriveOptions{
+		RatePerSecond: *rate,
+		Limit:         *limit,
+		DryRun:        *dryRun,
+	}
+	if *toArn != "" {
+		if opts.Destination, err = e.client.NewQueueContext(ctx, *toArn); err != nil {
+			return fmt.Errorf("e.client.NewQueueContext: %w", err)
+		}
+	}
+	if *attr != "" {
+		name, value, ok := strings.Cut(*attr, "=")
+		if !ok {
+			return fmt.Errorf("-attr %q is not NAME=VALUE", *attr)
+		}
+		opts.AttributeName, opts.AttributeValue = name, value
+	}
+	if *body != "" {
+		if opts.BodyPattern, err = regexp.Compile(*body); err != nil {
+			return fmt.Errorf("regexp.Compile: %w", err)
+		}
+	}
+
+	result, err := dlq.RedriveContext(ctx, opts)
+	if result != nil {
+		rows := make([][]string, 0, len(result.Messages))
+		for _, m := range result.Messages {
+			rows = append(rows, []string{m.MessageID, m.Destination, strconv.FormatBool(m.Moved), m.Body})
+		}
+		if perr := e.out.print(result, []string{"ID", "DESTINATION", "MOVED", "BODY"}, rows); perr != nil {
+			return perr
+		}
+		if !e.out.json {
+			fmt.Fprintf(e.out.w, "moved: %d, selected: %d, skipped: %d\n", result.Moved(), len(result.Messages), result.Skipped)
+		}
+	}
+	if err != nil {
+		return fmt.Errorf("dlq.RedriveContext: %w", err)
+	}
+
+	return nil
+}
+
+// attributes shows the attributes of a queue, topic or subscription.
+func attributes(ctx context.Context, e *env, args []string) error {
+	fs := flag.NewFlagSet("attributes", flag.ExitOnError)
+	queueArn := fs.String("queue", "", "queue ARN")
+	topicArn := fs.String("topic", "", "topic ARN")
+	subscriptionArn := fs.String("subscription", "", "subscription ARN")
+	if err := parse(fs, args); err != nil {
+		return err
+	}
+
+	switch {
+	case *queueArn != "":
+		queue, err := e.client.NewQueueContext(ctx, *queueArn)
+		if err != nil {
+			return fmt.Errorf("e.client.NewQueueContext: %w", err)
+		}
+		a, err := queue.Attributes(ctx)
+		if err != nil {
+			return fmt.Errorf("queue.Attributes: %w", err)
+		}
+		return e.out.printMap(a.Raw)
+	case *topicArn != "":
+		topic, err := e.client.NewTopicContext(ctx, *topicArn)
+		if err != nil {
+			return fmt.Errorf("e.client.NewTopicContext: %w", err)
+		}
+		a, err := topic.Attributes(ctx)
+		if err != nil {
+			return fmt.Errorf("topic.Attributes: %w", err)
+		}
+		return e.out.printMap(a.Raw)
+	case *subscriptionArn != "":
+		subscription, err := e.client.NewSubscriptionContext(ctx, *subscriptionArn)
+		if err != nil {
+			return fmt.Errorf("e.client.NewSubscriptionContext: %w", err)
+		}
+		a, err := subscription.Attributes(ctx)
+		if err != nil {
+			return fmt.Errorf("subscription.Attributes: %w", err)
+		}
+		return e.out.printMap(a.Raw)
+	}
+
+	return errors.New("one of -queue, -topic or -subscription is required")
+}
+
+// setAttributes sets attributes of a queue, topic or subscription.
+func setAttributes(ctx context.Context, e *env, args []string) error {
+	fs := flag.NewFlagSet("set-attributes", flag.ExitOnError)
+	queueArn := fs.String("queue", "", "queue ARN")
+	topicArn := fs.String("topic", "", "topic ARN")
+	subscriptionArn := fs.String("subscription", "", "subscription ARN")
+	attrs := keyValueFlag{}
+	fs.Var(attrs, "attr", "attribute as KEY=VALUE, repeatable")
+	if err := parse(fs, args); err != nil {
+		return err
+	}
+	if len(attrs) == 0 {
+		return errors.New("-attr is required")
+	}
+
+	switch {
+	case *queueArn != "":
+		queue, err := e.client.NewQueueContext(ctx, *queueArn)
+		if err != nil {
+			return fmt.Errorf("e.client.NewQueueContext: %w", err)
+		}
+		if err := queue.SetAttributes(ctx, pubsub.QueueOptions{Attributes: attrs}); err != nil {
+			return fmt.Errorf("queue.SetAttributes: %w", err)
+		}
+		return resource{Name: queue.Name(), Arn: queue.Arn(), Url: queue.Url()}.print(e.out)
+	case *topicArn != "":
+		topic, err := e.client.NewTopicContext(ctx, *topicArn)
+		if err != nil {
+			return fmt.Errorf("e.client.NewTopicContext: %w", err)
+		}
+		if err := topic.SetAttributes(ctx, pubsub.TopicOptions{Attributes: attrs}); err != nil {
+			return fmt.Errorf("topic.SetAttributes: %w", err)
+		}
+		return resource{Name: topic.Name(), Arn: topic.Arn()}.print(e.out)
+	case *subscriptionArn != "":
+		subscription, err := e.client.NewSubscriptionContext(ctx, *subscriptionArn)
+		if err != nil {
+			return fmt.Errorf("e.client.NewSubscriptionContext: %w", err)
+		}
+		if err := subscription.SetAttributes(ctx, pubsub.SubscriptionOptions{Attributes: attrs}); err != nil {
+			return fmt.Errorf("subscription.SetAttributes: %w", err)
+		}
+		return resource{Arn: subscription.Arn()}.print(e.out)
+	}
+
+	return errors.New("one of -queue, -topic or -subscription is required")
+}
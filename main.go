@@ -1,7 +1,191 @@
 package main
 
-import "fmt"
+import (
+	"context"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/config"
+	"github.com/aws/aws-sdk-go-v2/service/sns"
+	"github.com/aws/aws-sdk-go-v2/service/sqs"
+	"github.com/sonkibon/go-samples/pubsub"
+)
+
+// command is a subcommand of the CLI.
+type command struct {
+	name    string
+	usage   string
+	summary string
+	run     func(ctx context.Context, env *env, args []string) error
+}
+
+// env is the state shared by the subcommands.
+type env struct {
+	client *pubsub.PubsubClient
+	out    *printer
+}
+
+// commands are the subcommands in the order they are listed in the usage.
+var commands = []command{
+	{"create-queue", "-name NAME [-dlq ARN -max-receive N] [-attr KEY=VALUE]...", "create a queue", createQueue},
+	{"delete-queue", "-queue ARN [-force]", "delete a queue, refusing if it has messages unless -force", deleteQueue},
+	{"list-queues", "[-prefix PREFIX]", "list queues", listQueues},
+	{"create-topic", "-name NAME [-attr KEY=VALUE]...", "create a topic", createTopic},
+	{"delete-topic", "-topic ARN [-cascade [-delete-queues [-delete-dlqs]] [-force]]", "delete a topic, optionally with its subscriptions and queues", deleteTopic},
+	{"list-topics", "", "list topics", listTopics},
+	{"list-subscriptions", "[-topic ARN]", "list subscriptions, of every topic or of one", listSubscriptions},
+	{"subscribe", "-topic ARN -queue ARN [-grant] [-attr KEY=VALUE]...", "subscribe a queue to a topic", subscribe},
+	{"unsubscribe", "-subscription ARN", "delete a subscription", unsubscribe},
+	{"send", "-queue ARN -body BODY [-attr KEY=VALUE]...", "send a message to a queue", send},
+	{"publish", "-topic ARN -message MESSAGE [-attr KEY=VALUE]...", "publish a message to a topic", publish},
+	{"receive", "-queue ARN [-max N] [-wait SECONDS] [-ack]", "receive messages, deleting them with -ack", receive},
+	{"peek", "-queue ARN [-max N] [-wait SECONDS]", "receive messages and make them visible again; counts toward the maxReceiveCount of a RedrivePolicy", peek},
+	{"purge", "-queue ARN", "delete every message in a queue", purge},
+	{"redrive", "-dlq ARN [-to ARN] [-rate N] [-limit N] [-attr NAME=VALUE] [-body REGEXP] [-dry-run]", "move messages from a dead-letter queue to their source queue or to -to", redrive},
+	{"attributes", "-queue ARN | -topic ARN | -subscription ARN", "show the attributes of a queue, topic or subscription", attributes},
+	{"set-attributes", "-queue ARN | -topic ARN | -subscription ARN -attr KEY=VALUE...", "set attributes of a queue, topic or subscription", setAttributes},
+	{"plan", "-file FILE", "show the changes that apply would make", plan},
+	{"apply", "-file FILE", "create and update the resources of a topology file", apply},
+	{"drift", "-arn ARN -file FILE", "compare the resources connected to a topic or queue with a topology file", drift},
+	{"graph", "-arn ARN | -file FILE [-format dot|mermaid|yaml]", "draw the resources connected to a topic or queue, or a topology file", graph},
+}
+
+// newPubsubClient returns a PubsubClient for the optional region and endpoint, e.g. LocalStack. Without a region,
+// the region of the environment or the profile is used.
+func newPubsubClient(ctx context.Context, region, endpoint string) (*pubsub.PubsubClient, error) {
+	var opts []func(*config.LoadOptions) error
+	if region != "" {
+		opts = append(opts, config.WithRegion(region))
+	}
+	if endpoint != "" {
+		opts = append(opts, config.WithEndpointResolverWithOptions(aws.EndpointResolverWithOptionsFunc(
+			func(service, region string, options ...interface{}) (aws.Endpoint, error) {
+				return aws.Endpoint{URL: endpoint, SigningRegion: region}, nil
+			},
+		)))
+	}
+
+	cfg, err := config.LoadDefaultConfig(ctx, opts...)
+	if err != nil {
+		return nil, fmt.Errorf("config.LoadDefaultConfig: %w", err)
+	}
+
+	return &pubsub.PubsubClient{
+		SQS: sqs.NewFromConfig(cfg),
+		SNS: sns.NewFromConfig(cfg),
+	}, nil
+}
+
+// usage prints the global flags and the subcommands.
+func usage() {
+	fmt.Fprintf(flag.CommandLine.Output(), "Usage: %s [flags] <command> [command flags]\n\nFlags:\n", os.Args[0])
+	flag.PrintDefaults()
+	fmt.Fprintln(flag.CommandLine.Output(), "\nCommands:")
+	for _, c := range commands {
+		fmt.Fprintf(flag.CommandLine.Output(), "  %-18s %s\n", c.name, c.summary)
+		if c.usage != "" {
+			fmt.Fprintf(flag.CommandLine.Output(), "  %-18s   %s\n", "", c.usage)
+		}
+	}
+}
 
 func main() {
-	fmt.Println("Hello, World!")
+	var (
+		region   = flag.String("region", "", "AWS region (default: AWS_REGION or the region of the profile)")
+		endpoint = flag.String("endpoint", "", "endpoint URL override, e.g. http://localhost:4566")
+		output   = flag.String("output", "table", "output format: table or json")
+	)
+	flag.Usage = usage
+	flag.Parse()
+
+	if flag.NArg() == 0 {
+		usage()
+		os.Exit(2)
+	}
+
+	out, err := newPrinter(os.Stdout, *output)
+	if err != nil {
+		log.Fatalf("newPrinter: %v", err)
+	}
+
+	name, args := flag.Arg(0), flag.Args()[1:]
+	for _, c := range commands {
+		if c.name != name {
+			continue
+		}
+
+		ctx := context.Background()
+		client, err := newPubsubClient(ctx, *region, *endpoint)
+		if err != nil {
+			log.Fatalf("newPubsubClient: %v", err)
+		}
+		if err := c.run(ctx, &env{client: client, out: out}, args); err != nil {
+			log.Fatalf("%s: %v", c.name, err)
+		}
+		return
+	}
+
+	usage()
+	log.Fatalf("unknown command: %s", name)
+}
+
+// keyValueFlag is a repeatable KEY=VALUE flag.
+type keyValueFlag map[string]string
+
+// String returns the pairs sorted by key.
+func (f keyValueFlag) String() string {
+	pairs := make([]string, 0, len(f))
+	for k, v := range f {
+		pairs = append(pairs, k+"="+v)
+	}
+	sort.Strings(pairs)
+
+	return strings.Join(pairs, ",")
+}
+
+// Set adds a KEY=VALUE pair.
+func (f keyValueFlag) Set(s string) error {
+	k, v, ok := strings.Cut(s, "=")
+	if !ok {
+		return fmt.Errorf("%q is not KEY=VALUE", s)
+	}
+	f[k] = v
+
+	return nil
+}
+
+// opts returns the pairs in the format of the Create methods of PubsubClient.
+func (f keyValueFlag) opts() map[string]*string {
+	out := make(map[string]*string, len(f))
+	for k, v := range f {
+		out[k] = aws.String(v)
+	}
+
+	return out
+}
+
+// attributes returns the pairs as String message attributes.
+func (f keyValueFlag) attributes() *pubsub.Attributes {
+	a := pubsub.NewAttributes()
+	for _, k := range sortedKeys(f) {
+		a.String(k, f[k])
+	}
+
+	return a
+}
+
+// sortedKeys returns the keys of m in order.
+func sortedKeys(m map[string]string) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	return keys
 }
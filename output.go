@@ -0,0 +1,60 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+	"text/tabwriter"
+)
+
+// printer writes command results as a table or as JSON.
+type printer struct {
+	w    io.Writer
+	json bool
+}
+
+// newPrinter returns a printer for the output format.
+func newPrinter(w io.Writer, format string) (*printer, error) {
+	switch format {
+	case "table":
+		return &printer{w: w}, nil
+	case "json":
+		return &printer{w: w, json: true}, nil
+	}
+
+	return nil, fmt.Errorf("unknown output format: %s", format)
+}
+
+// print writes v as indented JSON, or the rows under the header as a table.
+func (p *printer) print(v interface{}, header []string, rows [][]string) error {
+	if p.json {
+		enc := json.NewEncoder(p.w)
+		enc.SetIndent("", "  ")
+		if err := enc.Encode(v); err != nil {
+			return fmt.Errorf("enc.Encode: %w", err)
+		}
+		return nil
+	}
+
+	tw := tabwriter.NewWriter(p.w, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, strings.Join(header, "\t"))
+	for _, row := range rows {
+		fmt.Fprintln(tw, strings.Join(row, "\t"))
+	}
+	if err := tw.Flush(); err != nil {
+		return fmt.Errorf("tw.Flush: %w", err)
+	}
+
+	return nil
+}
+
+// printMap writes the map as JSON, or as a KEY VALUE table sorted by key.
+func (p *printer) printMap(m map[string]string) error {
+	rows := make([][]string, 0, len(m))
+	for _, k := range sortedKeys(m) {
+		rows = append(rows, []string{k, m[k]})
+	}
+
+	return p.print(m, []string{"KEY", "VALUE"}, rows)
+}
@@ -0,0 +1,217 @@
+package pubsub
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	snstypes "github.com/aws/aws-sdk-go-v2/service/sns/types"
+	sqstypes "github.com/aws/aws-sdk-go-v2/service/sqs/types"
+)
+
+const (
+	// MaxMessageAttributes is the maximum number of message attributes allowed by Amazon SQS and Amazon SNS.
+	MaxMessageAttributes = 10
+	// MaxMessageAttributeNameLength is the maximum length of a message attribute name.
+	MaxMessageAttributeNameLength = 256
+
+	AttributeDataTypeString = "String"
+	AttributeDataTypeNumber = "Number"
+	AttributeDataTypeBinary = "Binary"
+)
+
+// reservedAttributePrefixes are the name prefixes reserved by AWS, compared case-insensitively.
+var reservedAttributePrefixes = []string{"aws.", "amazon."}
+
+// attributeValue represents a message attribute value independent of the SDK types.
+type attributeValue struct {
+	dataType    string
+	stringValue string
+	binaryValue []byte
+}
+
+// Attributes builds message attributes that can be converted to both Amazon SQS and Amazon SNS types.
+// The first validation error is kept and returned by SQS and SNS.
+type Attributes struct {
+	names  []string
+	values map[string]attributeValue
+	err    error
+}
+
+// NewAttributes returns an empty attributes builder.
+func NewAttributes() *Attributes {
+	return &Attributes{values: make(map[string]attributeValue)}
+}
+
+// String sets a String attribute.
+func (a *Attributes) String(name, value string) *Attributes {
+	return a.set(name, attributeValue{dataType: AttributeDataTypeString, stringValue: value})
+}
+
+// Int sets a Number attribute from an integer.
+func (a *Attributes) Int(name string, value int64) *Attributes {
+	return a.set(name, attributeValue{dataType: AttributeDataTypeNumber, stringValue: strconv.FormatInt(value, 10)})
+}
+
+// Float sets a Number attribute from a floating point number.
+func (a *Attributes) Float(name string, value float64) *Attributes {
+	return a.set(name, attributeValue{dataType: AttributeDataTypeNumber, stringValue: strconv.FormatFloat(value, 'f', -1, 64)})
+}
+
+// Binary sets a Binary attribute.
+func (a *Attributes) Binary(name string, value []byte) *Attributes {
+	return a.set(name, attributeValue{dataType: AttributeDataTypeBinary, binaryValue: value})
+}
+
+// Custom sets an attribute with a custom type label, e.g. "String.uuid" or "Number.int".
+func (a *Attributes) Custom(name, dataType, value string) *Attributes {
+	base, _, _ := strings.Cut(dataType, ".")
+	switch base {
+	case AttributeDataTypeString, AttributeDataTypeNumber:
+		return a.set(name, attributeValue{dataType: dataType, stringValue: value})
+	case AttributeDataTypeBinary:
+		return a.set(name, attributeValue{dataType: dataType, binaryValue: []byte(value)})
+	}
+
+	return a.fail(fmt.Errorf("attribute %q: unsupported data type %q", name, dataType))
+}
+
+// Len returns the number of attributes set.
+func (a *Attributes) Len() int {
+	if a == nil {
+		return 0
+	}
+
+	return len(a.names)
+}
+
+// Err returns the first validation error, if any.
+func (a *Attributes) Err() error {
+	if a == nil {
+		return nil
+	}
+
+	return a.err
+}
+
+// SQS converts the attributes to the Amazon SQS message attribute type.
+func (a *Attributes) SQS() (map[string]sqstypes.MessageAttributeValue, error) {
+	if a == nil || len(a.names) == 0 {
+		return nil, a.Err()
+	}
+	if a.err != nil {
+		return nil, a.err
+	}
+
+	out := make(map[string]sqstypes.MessageAttributeValue, len(a.names))
+	for _, name := range a.names {
+		v := a.values[name]
+		out[name] = sqstypes.MessageAttributeValue{
+			DataType:    aws.String(v.dataType),
+			StringValue: v.stringPtr(),
+			BinaryValue: v.binaryValue,
+		}
+	}
+
+	return out, nil
+}
+
+// SNS converts the attributes to the Amazon SNS message attribute type.
+func (a *Attributes) SNS() (map[string]snstypes.MessageAttributeValue, error) {
+	if a == nil || len(a.names) == 0 {
+		return nil, a.Err()
+	}
+	if a.err != nil {
+		return nil, a.err
+	}
+
+	out := make(map[string]snstypes.MessageAttributeValue, len(a.names))
+	for _, name := range a.names {
+		v := a.values[name]
+		out[name] = snstypes.MessageAttributeValue{
+			DataType:    aws.String(v.dataType),
+			StringValue: v.stringPtr(),
+			BinaryValue: v.binaryValue,
+		}
+	}
+
+	return out, nil
+}
+
+// set validates and stores an attribute, replacing any previous value with the same name.
+func (a *Attributes) set(name string, v attributeValue) *Attributes {
+	if a.err != nil {
+		return a
+	}
+	if err := validateAttributeName(name); err != nil {
+		return a.fail(err)
+	}
+	if v.isBinary() && len(v.binaryValue) == 0 || !v.isBinary() && v.stringValue == "" {
+		return a.fail(fmt.Errorf("attribute %q: value must not be empty", name))
+	}
+
+	if a.values == nil {
+		a.values = make(map[string]attributeValue)
+	}
+	if _, ok := a.values[name]; !ok {
+		if len(a.names) >= MaxMessageAttributes {
+			return a.fail(fmt.Errorf("attribute %q: exceeds the limit of %d attributes", name, MaxMessageAttributes))
+		}
+		a.names = append(a.names, name)
+	}
+	a.values[name] = v
+
+	return a
+}
+
+// fail records the first error.
+func (a *Attributes) fail(err error) *Attributes {
+	if a.err == nil {
+		a.err = err
+	}
+
+	return a
+}
+
+// isBinary reports whether the value has the Binary data type or a custom Binary type.
+func (v attributeValue) isBinary() bool {
+	base, _, _ := strings.Cut(v.dataType, ".")
+	return base == AttributeDataTypeBinary
+}
+
+// stringPtr returns the string value, or nil for binary values.
+func (v attributeValue) stringPtr() *string {
+	if v.isBinary() {
+		return nil
+	}
+
+	return aws.String(v.stringValue)
+}
+
+// validateAttributeName checks a message attribute name against the naming rules of Amazon SQS and Amazon SNS.
+func validateAttributeName(name string) error {
+	if name == "" {
+		return errors.New("attribute name must not be empty")
+	}
+	if len(name) > MaxMessageAttributeNameLength {
+		return fmt.Errorf("attribute %q: name exceeds %d characters", name, MaxMessageAttributeNameLength)
+	}
+	for _, r := range name {
+		if !(r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r >= '0' && r <= '9' || r == '-' || r == '_' || r == '.') {
+			return fmt.Errorf("attribute %q: name contains invalid character %q", name, r)
+		}
+	}
+	if strings.HasPrefix(name, ".") || strings.HasSuffix(name, ".") || strings.Contains(name, "..") {
+		return fmt.Errorf("attribute %q: name must not start or end with a period or contain consecutive periods", name)
+	}
+	lower := strings.ToLower(name)
+	for _, prefix := range reservedAttributePrefixes {
+		if strings.HasPrefix(lower, prefix) {
+			return fmt.Errorf("attribute %q: name uses the reserved prefix %q", name, prefix)
+		}
+	}
+
+	return nil
+}
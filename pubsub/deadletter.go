@@ -0,0 +1,135 @@
+package pubsub
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/sqs"
+	"github.com/aws/aws-sdk-go-v2/service/sqs/types"
+)
+
+const (
+	// AttributeDeadLetterError is the attribute recording the handler error of a forwarded message.
+	AttributeDeadLetterError = "DeadLetterError"
+	// AttributeDeadLetterSourceQueue is the attribute recording the ARN of the queue the message was received from.
+	AttributeDeadLetterSourceQueue = "DeadLetterSourceQueue"
+	// AttributeDeadLetterReceiveCount is the attribute recording the receive count of the message.
+	AttributeDeadLetterReceiveCount = "DeadLetterReceiveCount"
+	// AttributeDeadLetterTimestamp is the attribute recording when the message was forwarded, in RFC 3339.
+	AttributeDeadLetterTimestamp = "DeadLetterTimestamp"
+
+	// maxDeadLetterErrorLength is the maximum length of the recorded error text.
+	maxDeadLetterErrorLength = 1024
+)
+
+// deadLetterAttributeNames are the failure attributes in the order they are added.
+var deadLetterAttributeNames = []string{
+	AttributeDeadLetterError,
+	AttributeDeadLetterSourceQueue,
+	AttributeDeadLetterReceiveCount,
+	AttributeDeadLetterTimestamp,
+}
+
+// DeadLetterOptions configures the forwarding of failed messages to a dead-letter queue.
+type DeadLetterOptions struct {
+	// Queue receives the failed messages.
+	Queue *Queue
+	// IncludeDropped also forwards messages whose handler error is classified with Drop.
+	IncludeDropped bool
+}
+
+// ForwardToDeadLetter makes consume send messages whose handler error is classified with DeadLetter to the
+// dead-letter queue, with their body and attributes and the failure attributes, and then delete them.
+// Failure attributes are added in the order error, source queue, receive count and timestamp while the
+// attribute limit allows.
+func (q *Queue) ForwardToDeadLetter(opts DeadLetterOptions) *Queue {
+	q.deadLetter = &opts
+	return q
+}
+
+// forwards returns whether a message with the action is forwarded to the dead-letter queue.
+func (q *Queue) forwards(action Action) bool {
+	if q.deadLetter == nil || q.deadLetter.Queue == nil {
+		return false
+	}
+
+	return action == ActionDeadLetter || action == ActionDrop && q.deadLetter.IncludeDropped
+}
+
+// deadLetterTargetArn returns the arn of the dead-letter queue of the queue, or "" if it has none.
+func (q *Queue) deadLetterTargetArn(ctx context.Context) (string, error) {
+	output, err := q.client.SQS.GetQueueAttributes(ctx, &sqs.GetQueueAttributesInput{
+		AttributeNames: []types.QueueAttributeName{types.QueueAttributeNameRedrivePolicy},
+		QueueUrl:       aws.String(q.queueUrl),
+	})
+	if err != nil {
+		return "", fmt.Errorf("q.client.SQS.GetQueueAttributes: %w", err)
+	}
+
+	policy, err := parseRedrivePolicy(output.Attributes[QueueAttributeRedrivePolicy])
+	if err != nil {
+		return "", fmt.Errorf("parseRedrivePolicy: %w", err)
+	}
+	if policy == nil {
+		return "", nil
+	}
+
+	return policy.DeadLetterTargetArn, nil
+}
+
+// forwardToDeadLetter sends the message to the dead-letter queue and deletes it from the queue.
+func (q *Queue) forwardToDeadLetter(ctx context.Context, m types.Message, handlerErr error) error {
+	attributes := make(map[string]types.MessageAttributeValue, MaxMessageAttributes)
+	for k, v := range m.MessageAttributes {
+		attributes[k] = v
+	}
+
+	errText := truncateUTF8(handlerErr.Error(), maxDeadLetterErrorLength)
+	receiveCount := m.Attributes[string(types.MessageSystemAttributeNameApproximateReceiveCount)]
+	if _, err := strconv.Atoi(receiveCount); err != nil {
+		receiveCount = "0"
+	}
+	values := map[string]types.MessageAttributeValue{
+		AttributeDeadLetterError:        {DataType: aws.String(AttributeDataTypeString), StringValue: aws.String(errText)},
+		AttributeDeadLetterSourceQueue:  {DataType: aws.String(AttributeDataTypeString), StringValue: aws.String(q.queueArn)},
+		AttributeDeadLetterReceiveCount: {DataType: aws.String(AttributeDataTypeNumber), StringValue: aws.String(receiveCount)},
+		AttributeDeadLetterTimestamp:    {DataType: aws.String(AttributeDataTypeString), StringValue: aws.String(time.Now().UTC().Format(time.RFC3339Nano))},
+	}
+	for _, name := range deadLetterAttributeNames {
+		if _, ok := attributes[name]; !ok && len(attributes) >= MaxMessageAttributes {
+			break
+		}
+		attributes[name] = values[name]
+	}
+
+	if err := q.deadLetter.Queue.Send(ctx, aws.ToString(m.Body), attributes); err != nil {
+		return fmt.Errorf("q.deadLetter.Queue.Send: %w", err)
+	}
+	if _, err := q.client.SQS.DeleteMessage(ctx, &sqs.DeleteMessageInput{
+		QueueUrl:      aws.String(q.queueUrl),
+		ReceiptHandle: m.ReceiptHandle,
+	}); err != nil {
+		return fmt.Errorf("q.SQS.DeleteMessage: %w", err)
+	}
+
+	return nil
+}
+
+// truncateUTF8 returns s cut to at most n bytes on a rune boundary, with invalid UTF-8 replaced, since SQS rejects
+// attribute values that are not valid UTF-8.
+func truncateUTF8(s string, n int) string {
+	s = strings.ToValidUTF8(s, "\uFFFD")
+	if len(s) <= n {
+		return s
+	}
+	for n > 0 && !utf8.RuneStart(s[n]) {
+		n--
+	}
+
+	return s[:n]
+}
@@ -0,0 +1,329 @@
+package pubsub
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strconv"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/aws/arn"
+	"github.com/aws/aws-sdk-go-v2/service/sns"
+	"github.com/aws/aws-sdk-go-v2/service/sqs"
+	"github.com/aws/aws-sdk-go-v2/service/sqs/types"
+)
+
+// Read-only attributes are left out of crawled topologies, so that a crawled topology can be applied.
+var (
+	readOnlyTopicAttributes = []string{
+		"TopicArn", "Owner", "SubscriptionsPending", "SubscriptionsConfirmed", "SubscriptionsDeleted",
+		"EffectiveDeliveryPolicy",
+	}
+	readOnlyQueueAttributes = []string{
+		"QueueArn", "ApproximateNumberOfMessages", "ApproximateNumberOfMessagesNotVisible",
+		"ApproximateNumberOfMessagesDelayed", "CreatedTimestamp", "LastModifiedTimestamp",
+	}
+	readOnlySubscriptionAttributes = []string{
+		"SubscriptionArn", "TopicArn", "Owner", "Protocol", "Endpoint", "ConfirmationWasAuthenticated",
+		"PendingConfirmation", "SubscriptionPrincipal", "EffectiveDeliveryPolicy",
+	}
+)
+
+// CrawlTopology returns the topology reachable from a topic or queue arn, following the SQS subscriptions of
+// topics, the topics that queues are subscribed to, and the redrive policies of queues in both directions.
+func (c *PubsubClient) CrawlTopology(ctx context.Context, resourceArn string) (*Topology, error) {
+	cr := &crawler{client: c, seen: make(map[string]bool), topology: &Topology{}}
+	if err := cr.visit(ctx, resourceArn); err != nil {
+		return nil, err
+	}
+
+	t := cr.topology
+	sort.Slice(t.Topics, func(i, j int) bool { return t.Topics[i].Name < t.Topics[j].Name })
+	sort.Slice(t.Queues, func(i, j int) bool { return t.Queues[i].Name < t.Queues[j].Name })
+	sort.Slice(t.Subscriptions, func(i, j int) bool { return t.Subscriptions[i].name() < t.Subscriptions[j].name() })
+
+	return t, nil
+}
+
+// crawler walks the resources connected to a topic or queue.
+type crawler struct {
+	client   *PubsubClient
+	seen     map[string]bool
+	topology *Topology
+	// subscriptions are every subscription of the account, listed once when the first queue is visited.
+	subscriptions []SubscriptionSummary
+}
+
+// visit adds the resource with the arn to the topology, then visits the resources connected to it.
+func (cr *crawler) visit(ctx context.Context, resourceArn string) error {
+	if cr.seen[resourceArn] {
+		return nil
+	}
+	cr.seen[resourceArn] = true
+
+	parsed, err := arn.Parse(resourceArn)
+	if err != nil {
+		return fmt.Errorf("arn.Parse(%s) : %w", resourceArn, err)
+	}
+
+	switch parsed.Service {
+	case "sns":
+		return cr.visitTopic(ctx, resourceArn, parsed.Resource)
+	case "sqs":
+		return cr.visitQueue(ctx, parsed)
+	}
+
+	return fmt.Errorf("%s is neither a topic nor a queue", resourceArn)
+}
+
+// visitTopic adds the topic and its SQS subscriptions, then visits the subscribed queues.
+func (cr *crawler) visitTopic(ctx context.Context, topicArn, topicName string) error {
+	output, err := cr.client.SNS.GetTopicAttributes(ctx, &sns.GetTopicAttributesInput{TopicArn: aws.String(topicArn)})
+	if err != nil {
+		return fmt.Errorf("cr.client.SNS.GetTopicAttributes(%s) : %w", topicArn, err)
+	}
+	cr.topology.Topics = append(cr.topology.Topics, TopicSpec{
+		Name:       topicName,
+		Attributes: withoutAttributes(output.Attributes, readOnlyTopicAttributes),
+	})
+
+	it := cr.client.ListSubscriptionsByTopic(topicArn)
+	for it.Next(ctx) {
+		s := it.Value()
+		if s.Protocol != *SubscriptionProtocolSQS {
+			continue
+		}
+		if err := cr.addSubscription(ctx, s); err != nil {
+			return err
+		}
+		if err := cr.visit(ctx, s.Endpoint); err != nil {
+			return err
+		}
+	}
+	if err := it.Err(); err != nil {
+		return fmt.Errorf("cr.client.ListSubscriptionsByTopic(%s) : %w", topicArn, err)
+	}
+
+	return nil
+}
+
+// visitQueue adds the queue, then visits its dead-letter queue, the queues it is the dead-letter queue of,
+// and the topics it is subscribed to.
+// The queue is looked up in the account of its arn, since topics may have subscribers in other accounts.
+func (cr *crawler) visitQueue(ctx context.Context, parsed arn.ARN) error {
+	queueArn, queueName := parsed.String(), parsed.Resource
+	urlOutput, err := cr.client.SQS.GetQueueUrl(ctx, &sqs.GetQueueUrlInput{
+		QueueName:              aws.String(queueName),
+		QueueOwnerAWSAccountId: aws.String(parsed.AccountID),
+	})
+	if err != nil {
+		return fmt.Errorf("cr.client.SQS.GetQueueUrl(%s) : %w", queueName, err)
+	}
+	queue := &Queue{client: cr.client, queueArn: queueArn, queueName: queueName, queueUrl: aws.ToString(urlOutput.QueueUrl)}
+
+	output, err := cr.client.SQS.GetQueueAttributes(ctx, &sqs.GetQueueAttributesInput{
+		AttributeNames: []types.QueueAttributeName{types.QueueAttributeNameAll},
+		QueueUrl:       aws.String(queue.queueUrl),
+	})
+	if err != nil {
+		return fmt.Errorf("cr.client.SQS.GetQueueAttributes(%s) : %w", queue.queueUrl, err)
+	}
+
+	spec := QueueSpec{Name: queueName, Attributes: withoutAttributes(output.Attributes, readOnlyQueueAttributes)}
+	var deadLetterTargetArn string
+	policy, err := parseRedrivePolicy(spec.Attributes[QueueAttributeRedrivePolicy])
+	if err != nil {
+		return fmt.Errorf("parseRedrivePolicy(%s) : %w", queueName, err)
+	}
+	if policy != nil {
+		dlq, err := arn.Parse(policy.DeadLetterTargetArn)
+		if err != nil {
+			return fmt.Errorf("arn.Parse(%s) : %w", policy.DeadLetterTargetArn, err)
+		}
+		delete(spec.Attributes, QueueAttributeRedrivePolicy)
+		spec.DeadLetterQueue = dlq.Resource
+		spec.MaxReceiveCount = policy.MaxReceiveCount
+		deadLetterTargetArn = policy.DeadLetterTargetArn
+	}
+	cr.topology.Queues = append(cr.topology.Queues, spec)
+
+	if deadLetterTargetArn != "" {
+		if err := cr.visit(ctx, deadLetterTargetArn); err != nil {
+			return err
+		}
+	}
+
+	sources, err := queue.DeadLetterSourceQueues(ctx)
+	if err != nil {
+		return fmt.Errorf("queue.DeadLetterSourceQueues: %w", err)
+	}
+	for _, source := range sources {
+		if err := cr.visit(ctx, source.queueArn); err != nil {
+			return err
+		}
+	}
+
+	if cr.subscriptions == nil {
+		if cr.subscriptions, err = cr.client.ListSubscriptions().All(ctx); err != nil {
+			return fmt.Errorf("cr.client.ListSubscriptions: %w", err)
+		}
+	}
+	for _, s := range cr.subscriptions {
+		if s.Protocol != *SubscriptionProtocolSQS || s.Endpoint != queueArn {
+			continue
+		}
+		if err := cr.visit(ctx, s.TopicArn); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// addSubscription adds the subscription of a queue to a topic.
+func (cr *crawler) addSubscription(ctx context.Context, s SubscriptionSummary) error {
+	subscriptionArn := s.SubscriptionArn
+	// Subscriptions that are not confirmed yet have no attributes.
+	attributes := map[string]string{}
+	if _, err := arn.Parse(subscriptionArn); err == nil {
+		output, err := cr.client.SNS.GetSubscriptionAttributes(ctx, &sns.GetSubscriptionAttributesInput{SubscriptionArn: aws.String(subscriptionArn)})
+		if err != nil {
+			return fmt.Errorf("cr.client.SNS.GetSubscriptionAttributes(%s) : %w", subscriptionArn, err)
+		}
+		attributes = withoutAttributes(output.Attributes, readOnlySubscriptionAttributes)
+	}
+
+	topicArn, err := arn.Parse(s.TopicArn)
+	if err != nil {
+		return fmt.Errorf("arn.Parse(%s) : %w", s.TopicArn, err)
+	}
+	queueArn, err := arn.Parse(s.Endpoint)
+	if err != nil {
+		return fmt.Errorf("arn.Parse(%s) : %w", s.Endpoint, err)
+	}
+	cr.topology.Subscriptions = append(cr.topology.Subscriptions, SubscriptionSpec{
+		Topic:      topicArn.Resource,
+		Queue:      queueArn.Resource,
+		Attributes: attributes,
+	})
+
+	return nil
+}
+
+// DriftType is how an actual resource differs from its declaration.
+type DriftType string
+
+const (
+	// DriftMissing is a declared resource that does not exist.
+	DriftMissing = DriftType("missing")
+	// DriftChanged is a resource whose attributes differ from the declared ones.
+	DriftChanged = DriftType("changed")
+	// DriftOrphaned is a resource that exists but is not declared.
+	DriftOrphaned = DriftType("orphaned")
+)
+
+// Drift is a resource that differs from its declaration.
+type Drift struct {
+	Kind ResourceKind
+	Name string
+	Type DriftType
+	// Attributes are the changed attributes, with Old the actual value and New the declared one.
+	Attributes []AttributeChange
+}
+
+// DetectDrift crawls the topology from a topic or queue arn and compares it with the declared topology.
+// Declared resources that are not reachable from the arn are reported missing.
+func (c *PubsubClient) DetectDrift(ctx context.Context, declared *Topology, resourceArn string) ([]Drift, error) {
+	actual, err := c.CrawlTopology(ctx, resourceArn)
+	if err != nil {
+		return nil, fmt.Errorf("c.CrawlTopology: %w", err)
+	}
+
+	return CompareTopology(declared, actual), nil
+}
+
+// CompareTopology returns how the actual topology differs from the declared one. Only the declared attributes
+// are compared, while the dead-letter queue and maxReceiveCount of queues are always compared.
+func CompareTopology(declared, actual *Topology) []Drift {
+	var drifts []Drift
+	compare := func(kind ResourceKind, declared, actual map[string]map[string]string) {
+		for _, name := range sortedKeys(declared) {
+			attributes, ok := actual[name]
+			if !ok {
+				drifts = append(drifts, Drift{Kind: kind, Name: name, Type: DriftMissing})
+				continue
+			}
+			if changes := attributeChanges(attributes, declared[name]); len(changes) > 0 {
+				drifts = append(drifts, Drift{Kind: kind, Name: name, Type: DriftChanged, Attributes: changes})
+			}
+		}
+		for _, name := range sortedKeys(actual) {
+			if _, ok := declared[name]; !ok {
+				drifts = append(drifts, Drift{Kind: kind, Name: name, Type: DriftOrphaned})
+			}
+		}
+	}
+
+	compare(ResourceTopic, declared.topicAttributes(), actual.topicAttributes())
+	compare(ResourceQueue, declared.queueAttributes(), actual.queueAttributes())
+	compare(ResourceSubscription, declared.subscriptionAttributes(), actual.subscriptionAttributes())
+
+	return drifts
+}
+
+// topicAttributes returns the attributes of the topics by name.
+func (t *Topology) topicAttributes() map[string]map[string]string {
+	m := make(map[string]map[string]string, len(t.Topics))
+	for _, s := range t.Topics {
+		m[s.Name] = s.Attributes
+	}
+
+	return m
+}
+
+// queueAttributes returns the attributes of the queues by name, with the dead-letter queue and maxReceiveCount
+// as the deadLetterQueue and maxReceiveCount attributes. A RedrivePolicy attribute is converted the same way,
+// as crawled queues are, unless it cannot be parsed.
+func (t *Topology) queueAttributes() map[string]map[string]string {
+	m := make(map[string]map[string]string, len(t.Queues))
+	for _, s := range t.Queues {
+		attributes := copyAttributes(s.Attributes)
+		if policy, err := parseRedrivePolicy(attributes[QueueAttributeRedrivePolicy]); err == nil && policy != nil {
+			s.DeadLetterQueue = policy.DeadLetterTargetArn
+			if parsed, err := arn.Parse(policy.DeadLetterTargetArn); err == nil {
+				s.DeadLetterQueue = parsed.Resource
+			}
+			s.MaxReceiveCount = policy.MaxReceiveCount
+			delete(attributes, QueueAttributeRedrivePolicy)
+		}
+		attributes["deadLetterQueue"] = s.DeadLetterQueue
+		if s.DeadLetterQueue != "" {
+			attributes["maxReceiveCount"] = strconv.FormatInt(s.maxReceiveCount(), 10)
+		}
+		m[s.Name] = attributes
+	}
+
+	return m
+}
+
+// subscriptionAttributes returns the attributes of the subscriptions by name.
+func (t *Topology) subscriptionAttributes() map[string]map[string]string {
+	m := make(map[string]map[string]string, len(t.Subscriptions))
+	for _, s := range t.Subscriptions {
+		m[s.name()] = s.Attributes
+	}
+
+	return m
+}
+
+// withoutAttributes returns a copy of the attributes without the names.
+func withoutAttributes(attributes map[string]string, names []string) map[string]string {
+	out := make(map[string]string, len(attributes))
+	for k, v := range attributes {
+		if !containsString(names, k) {
+			out[k] = v
+		}
+	}
+
+	return out
+}
@@ -0,0 +1,108 @@
+package pubsub
+
+import (
+	"encoding/json"
+	"fmt"
+)
+
+// EnvelopeType is the kind of envelope a message was delivered in.
+type EnvelopeType string
+
+const (
+	EnvelopeRaw         = EnvelopeType("raw")
+	EnvelopeSNS         = EnvelopeType("sns")
+	EnvelopeS3          = EnvelopeType("s3")
+	EnvelopeSNSS3       = EnvelopeType("sns+s3")
+	EnvelopeEventBridge = EnvelopeType("eventbridge")
+)
+
+const (
+	snsTypeNotification = "Notification"
+	s3EventSource       = "aws:s3"
+)
+
+// Event is a message unwrapped from its envelope.
+// Body is the innermost payload: the message body for raw messages, the SNS Message, the S3 event JSON,
+// or the EventBridge detail. The field of the detected envelope is set, and SNS is also set for EnvelopeSNSS3.
+type Event struct {
+	Envelope    EnvelopeType
+	Body        string
+	SNS         *SNSEvent
+	S3          *S3Event
+	EventBridge *EventBridgeEvent[json.RawMessage]
+}
+
+// envelopeProbe holds the fields used to tell envelopes apart.
+type envelopeProbe struct {
+	Type     string  `json:"Type"`
+	TopicArn string  `json:"TopicArn"`
+	Message  *string `json:"Message"`
+	Records  []struct {
+		EventSource string `json:"eventSource"`
+	} `json:"Records"`
+	Event      S3EventName     `json:"Event"`
+	DetailType *string         `json:"detail-type"`
+	Source     *string         `json:"source"`
+	Detail     json.RawMessage `json:"detail"`
+}
+
+// isS3 returns whether the probe looks like an S3 event notification or the s3:TestEvent message.
+func (p envelopeProbe) isS3() bool {
+	if p.Event == S3EventTest {
+		return true
+	}
+
+	return len(p.Records) > 0 && p.Records[0].EventSource == s3EventSource
+}
+
+// isSNS returns whether the probe looks like an SNS notification.
+func (p envelopeProbe) isSNS() bool {
+	return p.Type == snsTypeNotification && p.TopicArn != "" && p.Message != nil
+}
+
+// isEventBridge returns whether the probe looks like an EventBridge event.
+func (p envelopeProbe) isEventBridge() bool {
+	return p.DetailType != nil && p.Source != nil && p.Detail != nil
+}
+
+// DetectEvent inspects the message body and unwraps it from its envelope.
+// Bodies that are not JSON objects or do not match a known envelope are returned as EnvelopeRaw.
+func DetectEvent(body string) (Event, error) {
+	var probe envelopeProbe
+	if err := json.Unmarshal([]byte(body), &probe); err != nil {
+		return Event{Envelope: EnvelopeRaw, Body: body}, nil
+	}
+
+	switch {
+	case probe.isSNS():
+		var sns SNSEvent
+		if err := json.Unmarshal([]byte(body), &sns); err != nil {
+			return Event{}, fmt.Errorf("json.Unmarshal(sns) : %w", err)
+		}
+
+		var inner envelopeProbe
+		if err := json.Unmarshal([]byte(sns.Message), &inner); err == nil && inner.isS3() {
+			var s3 S3Event
+			if err := json.Unmarshal([]byte(sns.Message), &s3); err != nil {
+				return Event{}, fmt.Errorf("json.Unmarshal(s3) : %w", err)
+			}
+			return Event{Envelope: EnvelopeSNSS3, Body: sns.Message, SNS: &sns, S3: &s3}, nil
+		}
+
+		return Event{Envelope: EnvelopeSNS, Body: sns.Message, SNS: &sns}, nil
+	case probe.isS3():
+		var s3 S3Event
+		if err := json.Unmarshal([]byte(body), &s3); err != nil {
+			return Event{}, fmt.Errorf("json.Unmarshal(s3) : %w", err)
+		}
+		return Event{Envelope: EnvelopeS3, Body: body, S3: &s3}, nil
+	case probe.isEventBridge():
+		var eb EventBridgeEvent[json.RawMessage]
+		if err := json.Unmarshal([]byte(body), &eb); err != nil {
+			return Event{}, fmt.Errorf("json.Unmarshal(eventbridge) : %w", err)
+		}
+		return Event{Envelope: EnvelopeEventBridge, Body: string(eb.Detail), EventBridge: &eb}, nil
+	}
+
+	return Event{Envelope: EnvelopeRaw, Body: body}, nil
+}
@@ -0,0 +1,145 @@
+package pubsub
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"runtime/debug"
+	"time"
+)
+
+// ErrQueueNotEmpty is returned when deleting a queue that still has messages without forcing it.
+var ErrQueueNotEmpty = errors.New("queue is not empty")
+
+// PanicError is the error a recovered handler panic is converted to.
+type PanicError struct {
+	Value interface{}
+	Stack []byte
+}
+
+// newPanicError returns a PanicError for the recovered value with the stack of the current goroutine.
+func newPanicError(v interface{}) *PanicError {
+	return &PanicError{Value: v, Stack: debug.Stack()}
+}
+
+// Error returns the panic value and the stack trace.
+func (e *PanicError) Error() string {
+	return fmt.Sprintf("panic in handler: %v\n%s", e.Value, e.Stack)
+}
+
+// Unwrap returns the panic value if it is an error.
+func (e *PanicError) Unwrap() error {
+	if err, ok := e.Value.(error); ok {
+		return err
+	}
+
+	return nil
+}
+
+// Action is what the consumer does with a message whose handler returned an error.
+type Action int
+
+const (
+	// ActionRetry makes the message visible again after the requeue visibility timeout, or after the delay of RetryAfter.
+	ActionRetry Action = iota
+	// ActionDrop deletes the message.
+	ActionDrop
+	// ActionDeadLetter forwards the message to the dead-letter queue set with Queue.ForwardToDeadLetter, or else
+	// leaves it for the redrive policy of the queue to move it to the dead-letter queue. If the queue has no
+	// redrive policy either, the message is deleted as with ActionDrop.
+	ActionDeadLetter
+)
+
+// String returns the name of the action.
+func (a Action) String() string {
+	switch a {
+	case ActionRetry:
+		return "retry"
+	case ActionDrop:
+		return "drop"
+	case ActionDeadLetter:
+		return "dead-letter"
+	}
+
+	return fmt.Sprintf("Action(%d)", int(a))
+}
+
+// ClassifiedError is a handler error with the action to take on the message.
+type ClassifiedError struct {
+	Action Action
+	// Delay is the visibility timeout of a retried message. If zero, the requeue visibility timeout is used.
+	Delay time.Duration
+	Err   error
+}
+
+// Error returns the message of the wrapped error.
+func (e *ClassifiedError) Error() string {
+	return e.Err.Error()
+}
+
+// Unwrap returns the wrapped error.
+func (e *ClassifiedError) Unwrap() error {
+	return e.Err
+}
+
+// Retry wraps err so that the message is retried after the requeue visibility timeout.
+// It returns nil if err is nil.
+func Retry(err error) error {
+	if err == nil {
+		return nil
+	}
+
+	return &ClassifiedError{Action: ActionRetry, Err: err}
+}
+
+// RetryAfter wraps err so that the message is retried after d, rounded up to seconds and capped at 12 hours,
+// the maximum visibility timeout.
+// It returns nil if err is nil.
+func RetryAfter(err error, d time.Duration) error {
+	if err == nil {
+		return nil
+	}
+
+	return &ClassifiedError{Action: ActionRetry, Delay: d, Err: err}
+}
+
+// Drop wraps err so that the message is deleted. It returns nil if err is nil.
+func Drop(err error) error {
+	if err == nil {
+		return nil
+	}
+
+	return &ClassifiedError{Action: ActionDrop, Err: err}
+}
+
+// DeadLetter wraps err so that the message is sent to the dead-letter queue. It returns nil if err is nil.
+func DeadLetter(err error) error {
+	if err == nil {
+		return nil
+	}
+
+	return &ClassifiedError{Action: ActionDeadLetter, Err: err}
+}
+
+// Classify returns the action and retry delay of a handler error.
+// Errors without a ClassifiedError in their chain are retried.
+func Classify(err error) (Action, time.Duration) {
+	var ce *ClassifiedError
+	if errors.As(err, &ce) {
+		return ce.Action, ce.Delay
+	}
+
+	return ActionRetry, 0
+}
+
+// FromRetryable adapts a handler returning (retryable bool, err error) to a handler returning a classified error.
+// Retryable errors are wrapped with Retry and the others with Drop.
+func FromRetryable[T any](handler func(c context.Context, v T) (retryable bool, err error)) func(c context.Context, v T) error {
+	return func(ctx context.Context, v T) error {
+		retryable, err := handler(ctx, v)
+		if retryable {
+			return Retry(err)
+		}
+		return Drop(err)
+	}
+}
@@ -0,0 +1,104 @@
+package pubsub
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/service/sqs/types"
+)
+
+// EventBridgeEvent is the struct to map when sending messages to the queue via an Amazon EventBridge rule.
+// T is the type of the detail field.
+type EventBridgeEvent[T any] struct {
+	Version    string    `json:"version"`
+	ID         string    `json:"id"`
+	DetailType string    `json:"detail-type"`
+	Source     string    `json:"source"`
+	Account    string    `json:"account"`
+	Time       time.Time `json:"time"`
+	Region     string    `json:"region"`
+	Resources  []string  `json:"resources"`
+	Detail     T         `json:"detail"`
+}
+
+// ConsumeEventBridge maps the message to an EventBridgeEvent with a detail of type T and calls the consume method.
+func ConsumeEventBridge[T any](ctx context.Context, q *Queue, handler func(c context.Context, event EventBridgeEvent[T]) error) (*ConsumeResult, error) {
+	return q.consume(ctx, func(ctx context.Context, m types.Message) error {
+		var event EventBridgeEvent[T]
+		if err := json.Unmarshal([]byte(*m.Body), &event); err != nil {
+			q.logDecodeFailure(ctx, m, *m.Body, err)
+			return DeadLetter(fmt.Errorf("json.Unmarshal: %w", err))
+		}
+		return handler(ctx, event)
+	})
+}
+
+// eventBridgeRoute is the key of an EventBridgeRouter route.
+type eventBridgeRoute struct {
+	source     string
+	detailType string
+}
+
+// EventBridgeRouter dispatches EventBridge events to handlers by source and detail-type.
+// Routes registered with an empty detail-type match every detail-type of the source.
+type EventBridgeRouter struct {
+	routes map[eventBridgeRoute]func(context.Context, EventBridgeEvent[json.RawMessage]) error
+	// Fallback is called for events without a route. If nil, such events are dropped with an error.
+	Fallback func(c context.Context, event EventBridgeEvent[json.RawMessage]) error
+}
+
+// NewEventBridgeRouter returns a router without routes.
+func NewEventBridgeRouter() *EventBridgeRouter {
+	return &EventBridgeRouter{
+		routes: make(map[eventBridgeRoute]func(context.Context, EventBridgeEvent[json.RawMessage]) error),
+	}
+}
+
+// Handle registers a handler that receives the raw detail for the source and detail-type.
+func (r *EventBridgeRouter) Handle(source, detailType string, handler func(c context.Context, event EventBridgeEvent[json.RawMessage]) error) *EventBridgeRouter {
+	if r.routes == nil {
+		r.routes = make(map[eventBridgeRoute]func(context.Context, EventBridgeEvent[json.RawMessage]) error)
+	}
+	r.routes[eventBridgeRoute{source: source, detailType: detailType}] = handler
+
+	return r
+}
+
+// HandleEventBridge registers a handler on the router that receives the detail decoded as T.
+func HandleEventBridge[T any](r *EventBridgeRouter, source, detailType string, handler func(c context.Context, event EventBridgeEvent[T]) error) *EventBridgeRouter {
+	return r.Handle(source, detailType, func(ctx context.Context, raw EventBridgeEvent[json.RawMessage]) error {
+		event := EventBridgeEvent[T]{
+			Version:    raw.Version,
+			ID:         raw.ID,
+			DetailType: raw.DetailType,
+			Source:     raw.Source,
+			Account:    raw.Account,
+			Time:       raw.Time,
+			Region:     raw.Region,
+			Resources:  raw.Resources,
+		}
+		if len(raw.Detail) > 0 {
+			if err := json.Unmarshal(raw.Detail, &event.Detail); err != nil {
+				return DeadLetter(fmt.Errorf("json.Unmarshal(detail of %s/%s) : %w", raw.Source, raw.DetailType, err))
+			}
+		}
+		return handler(ctx, event)
+	})
+}
+
+// Dispatch calls the handler registered for the source and detail-type of the event.
+func (r *EventBridgeRouter) Dispatch(ctx context.Context, event EventBridgeEvent[json.RawMessage]) error {
+	if h, ok := r.routes[eventBridgeRoute{source: event.Source, detailType: event.DetailType}]; ok {
+		return h(ctx, event)
+	}
+	if h, ok := r.routes[eventBridgeRoute{source: event.Source}]; ok {
+		return h(ctx, event)
+	}
+	if r.Fallback != nil {
+		return r.Fallback(ctx, event)
+	}
+
+	return Drop(fmt.Errorf("no route for source=%s, detail-type=%s", event.Source, event.DetailType))
+}
@@ -0,0 +1,561 @@
+package pubsub
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	snstypes "github.com/aws/aws-sdk-go-v2/service/sns/types"
+)
+
+// Filter policy scopes.
+const (
+	FilterPolicyScopeMessageAttributes = "MessageAttributes"
+	FilterPolicyScopeMessageBody       = "MessageBody"
+)
+
+// filterPolicyOr is the key of the alternatives of a filter policy.
+const filterPolicyOr = "$or"
+
+// numericOperators are the comparison operators of numeric conditions.
+var numericOperators = []string{"=", "<", "<=", ">", ">="}
+
+// FilterCondition is one of the values a filter policy key matches. Build one with Equals, Prefix, Suffix,
+// EqualsIgnoreCase, AnythingBut, AnythingButPrefix, Numeric, NumericRange or Exists.
+type FilterCondition struct {
+	value interface{}
+	err   error
+}
+
+// Equals matches a string, a number, a boolean or, in the message body, null.
+func Equals(value interface{}) FilterCondition {
+	switch v := value.(type) {
+	case string, bool, nil:
+		return FilterCondition{value: v}
+	case int:
+		return FilterCondition{value: float64(v)}
+	case int64:
+		return FilterCondition{value: float64(v)}
+	case float64:
+		return FilterCondition{value: v}
+	}
+
+	return FilterCondition{err: fmt.Errorf("unsupported value %v of type %T", value, value)}
+}
+
+// Prefix matches strings starting with the prefix.
+func Prefix(prefix string) FilterCondition {
+	return FilterCondition{value: map[string]interface{}{"prefix": prefix}}
+}
+
+// Suffix matches strings ending with the suffix.
+func Suffix(suffix string) FilterCondition {
+	return FilterCondition{value: map[string]interface{}{"suffix": suffix}}
+}
+
+// EqualsIgnoreCase matches strings equal to the value regardless of case.
+func EqualsIgnoreCase(value string) FilterCondition {
+	return FilterCondition{value: map[string]interface{}{"equals-ignore-case": value}}
+}
+
+// AnythingBut matches present values other than the strings or numbers.
+func AnythingBut(values ...interface{}) FilterCondition {
+	list := make([]interface{}, 0, len(values))
+	for _, value := range values {
+		c := Equals(value)
+		if c.err != nil {
+			return c
+		}
+		if _, ok := c.value.(bool); ok || c.value == nil {
+			return FilterCondition{err: fmt.Errorf("anything-but accepts strings and numbers, got %v", value)}
+		}
+		list = append(list, c.value)
+	}
+
+	return FilterCondition{value: map[string]interface{}{"anything-but": list}}
+}
+
+// AnythingButPrefix matches present strings that do not start with the prefix.
+func AnythingButPrefix(prefix string) FilterCondition {
+	return FilterCondition{value: map[string]interface{}{"anything-but": map[string]interface{}{"prefix": prefix}}}
+}
+
+// Numeric matches numbers compared with the value by the operator, one of =, <, <=, > and >=.
+func Numeric(operator string, value float64) FilterCondition {
+	if !containsString(numericOperators, operator) {
+		return FilterCondition{err: fmt.Errorf("unknown numeric operator %q", operator)}
+	}
+
+	return FilterCondition{value: map[string]interface{}{"numeric": []interface{}{operator, value}}}
+}
+
+// NumericRange matches numbers between the bounds, e.g. NumericRange(">=", 0, "<", 100).
+func NumericRange(lowerOperator string, lower float64, upperOperator string, upper float64) FilterCondition {
+	if lowerOperator != ">" && lowerOperator != ">=" {
+		return FilterCondition{err: fmt.Errorf("lower bound operator %q is not > or >=", lowerOperator)}
+	}
+	if upperOperator != "<" && upperOperator != "<=" {
+		return FilterCondition{err: fmt.Errorf("upper bound operator %q is not < or <=", upperOperator)}
+	}
+	if lower > upper {
+		return FilterCondition{err: fmt.Errorf("lower bound %v is greater than upper bound %v", lower, upper)}
+	}
+
+	return FilterCondition{value: map[string]interface{}{"numeric": []interface{}{lowerOperator, lower, upperOperator, upper}}}
+}
+
+// Exists matches when the key is present, or absent if exists is false.
+func Exists(exists bool) FilterCondition {
+	return FilterCondition{value: map[string]interface{}{"exists": exists}}
+}
+
+// FilterPolicy builds an Amazon SNS subscription filter policy and evaluates it locally.
+// The first validation error is kept and returned by JSON and Match.
+type FilterPolicy struct {
+	scope string
+	root  map[string]interface{}
+	err   error
+}
+
+// NewFilterPolicy returns an empty filter policy on the message attributes.
+func NewFilterPolicy() *FilterPolicy {
+	return &FilterPolicy{scope: FilterPolicyScopeMessageAttributes, root: make(map[string]interface{})}
+}
+
+// NewBodyFilterPolicy returns an empty filter policy on the message body, which must be a JSON object.
+func NewBodyFilterPolicy() *FilterPolicy {
+	return &FilterPolicy{scope: FilterPolicyScopeMessageBody, root: make(map[string]interface{})}
+}
+
+// ParseFilterPolicy parses a filter policy JSON document with its scope, an empty scope meaning MessageAttributes.
+func ParseFilterPolicy(policy, scope string) (*FilterPolicy, error) {
+	if scope == "" {
+		scope = FilterPolicyScopeMessageAttributes
+	}
+	if scope != FilterPolicyScopeMessageAttributes && scope != FilterPolicyScopeMessageBody {
+		return nil, fmt.Errorf("unknown filter policy scope %q", scope)
+	}
+
+	var root map[string]interface{}
+	if err := json.Unmarshal([]byte(policy), &root); err != nil {
+		return nil, fmt.Errorf("json.Unmarshal: %w", err)
+	}
+	p := &FilterPolicy{scope: scope, root: root}
+	if err := p.validate(root); err != nil {
+		return nil, err
+	}
+
+	return p, nil
+}
+
+// Where adds a key matching any of the conditions.
+func (p *FilterPolicy) Where(key string, conditions ...FilterCondition) *FilterPolicy {
+	if len(conditions) == 0 {
+		return p.fail(fmt.Errorf("key %q: no conditions", key))
+	}
+
+	values := make([]interface{}, 0, len(conditions))
+	for _, c := range conditions {
+		if c.err != nil {
+			return p.fail(fmt.Errorf("key %q: %w", key, c.err))
+		}
+		values = append(values, c.value)
+	}
+
+	return p.set(key, values)
+}
+
+// Nested adds a key of the message body matching the nested policy, e.g. the fields of {"order": {...}}.
+// Only policies on the message body can be nested.
+func (p *FilterPolicy) Nested(key string, nested *FilterPolicy) *FilterPolicy {
+	if p.scope != FilterPolicyScopeMessageBody {
+		return p.fail(fmt.Errorf("key %q: nested keys require the %s scope", key, FilterPolicyScopeMessageBody))
+	}
+	if nested.err != nil {
+		return p.fail(fmt.Errorf("key %q: %w", key, nested.err))
+	}
+	if nested.scope != p.scope {
+		return p.fail(fmt.Errorf("key %q: nested policy of the %s scope", key, nested.scope))
+	}
+
+	return p.set(key, nested.root)
+}
+
+// Or adds alternatives, of which at least one must match in addition to the other keys of the policy.
+// The alternatives must have the scope of the policy.
+func (p *FilterPolicy) Or(alternatives ...*FilterPolicy) *FilterPolicy {
+	if len(alternatives) < 2 {
+		return p.fail(errors.New("$or needs at least two alternatives"))
+	}
+
+	values := make([]interface{}, 0, len(alternatives))
+	for _, alt := range alternatives {
+		if alt.err != nil {
+			return p.fail(fmt.Errorf("$or: %w", alt.err))
+		}
+		if alt.scope != p.scope {
+			return p.fail(fmt.Errorf("$or: alternative of the %s scope in a policy of the %s scope", alt.scope, p.scope))
+		}
+		values = append(values, alt.root)
+	}
+
+	return p.set(filterPolicyOr, values)
+}
+
+// Scope returns the scope of the policy, MessageAttributes or MessageBody.
+func (p *FilterPolicy) Scope() string {
+	return p.scope
+}
+
+// Err returns the first validation error, if any.
+func (p *FilterPolicy) Err() error {
+	return p.err
+}
+
+// JSON returns the policy as the JSON document of the FilterPolicy subscription attribute.
+func (p *FilterPolicy) JSON() (string, error) {
+	if p.err != nil {
+		return "", p.err
+	}
+
+	// Numeric operators are kept readable instead of being escaped as HTML.
+	var b strings.Builder
+	enc := json.NewEncoder(&b)
+	enc.SetEscapeHTML(false)
+	if err := enc.Encode(p.root); err != nil {
+		return "", fmt.Errorf("enc.Encode: %w", err)
+	}
+
+	return strings.TrimSuffix(b.String(), "\n"), nil
+}
+
+// set sets a key, failing if it is already set.
+func (p *FilterPolicy) set(key string, value interface{}) *FilterPolicy {
+	if _, ok := p.root[key]; ok {
+		return p.fail(fmt.Errorf("key %q is set twice", key))
+	}
+	p.root[key] = value
+
+	return p
+}
+
+// fail keeps the first error.
+func (p *FilterPolicy) fail(err error) *FilterPolicy {
+	if p.err == nil {
+		p.err = err
+	}
+
+	return p
+}
+
+// validate checks the structure of a parsed policy.
+func (p *FilterPolicy) validate(policy map[string]interface{}) error {
+	for key, value := range policy {
+		switch v := value.(type) {
+		case []interface{}:
+			if key == filterPolicyOr {
+				for _, alt := range v {
+					m, ok := alt.(map[string]interface{})
+					if !ok {
+						return fmt.Errorf("$or: %v is not an object", alt)
+					}
+					if err := p.validate(m); err != nil {
+						return err
+					}
+				}
+				continue
+			}
+			for _, c := range v {
+				if err := validateFilterCondition(c); err != nil {
+					return fmt.Errorf("key %q: %w", key, err)
+				}
+			}
+		case map[string]interface{}:
+			if p.scope != FilterPolicyScopeMessageBody {
+				return fmt.Errorf("key %q: nested keys require the %s scope", key, FilterPolicyScopeMessageBody)
+			}
+			if err := p.validate(v); err != nil {
+				return err
+			}
+		default:
+			return fmt.Errorf("key %q: %v is neither an array nor an object", key, value)
+		}
+	}
+
+	return nil
+}
+
+// validateFilterCondition checks that a condition is a scalar or an object with a single known operator.
+func validateFilterCondition(condition interface{}) error {
+	if isFilterScalar(condition) {
+		return nil
+	}
+	operator, ok := condition.(map[string]interface{})
+	if !ok || len(operator) != 1 {
+		return fmt.Errorf("%v is neither a value nor an object with one operator", condition)
+	}
+
+	for name, arg := range operator {
+		switch name {
+		case "prefix", "suffix", "equals-ignore-case":
+			if _, ok := arg.(string); !ok {
+				return fmt.Errorf("%s: %v is not a string", name, arg)
+			}
+		case "exists":
+			if _, ok := arg.(bool); !ok {
+				return fmt.Errorf("exists: %v is not a boolean", arg)
+			}
+		case "anything-but":
+			switch a := arg.(type) {
+			case string, float64:
+			case []interface{}:
+				for _, excluded := range a {
+					if _, ok := excluded.(string); !ok {
+						if _, ok := excluded.(float64); !ok {
+							return fmt.Errorf("anything-but: %v is neither a string nor a number", excluded)
+						}
+					}
+				}
+			case map[string]interface{}:
+				if _, ok := a["prefix"].(string); !ok || len(a) != 1 {
+					return fmt.Errorf("anything-but: %v is not a prefix operator", arg)
+				}
+			default:
+				return fmt.Errorf("anything-but: %v is neither a value, a list nor a prefix operator", arg)
+			}
+		case "numeric":
+			comparisons, ok := arg.([]interface{})
+			if !ok || len(comparisons) == 0 || len(comparisons)%2 != 0 {
+				return fmt.Errorf("numeric: %v is not a list of operators and numbers", arg)
+			}
+			for i := 0; i < len(comparisons); i += 2 {
+				operator, _ := comparisons[i].(string)
+				if !containsString(numericOperators, operator) {
+					return fmt.Errorf("numeric: unknown operator %v", comparisons[i])
+				}
+				if _, ok := comparisons[i+1].(float64); !ok {
+					return fmt.Errorf("numeric: %v is not a number", comparisons[i+1])
+				}
+			}
+		default:
+			return fmt.Errorf("unknown operator %q", name)
+		}
+	}
+
+	return nil
+}
+
+// isFilterScalar returns whether a decoded JSON value is a string, a number, a boolean or null.
+func isFilterScalar(value interface{}) bool {
+	switch value.(type) {
+	case string, float64, bool, nil:
+		return true
+	}
+
+	return false
+}
+
+// equalFilterScalars returns whether two values are equal scalars. Arrays and objects never match, and are not
+// compared with == which panics on them.
+func equalFilterScalars(a, b interface{}) bool {
+	return isFilterScalar(a) && isFilterScalar(b) && a == b
+}
+
+// Match returns whether SNS would deliver a message with the body and attributes to a subscription with the policy.
+// With the MessageBody scope, a body that is not a JSON object never matches.
+func (p *FilterPolicy) Match(body string, attributes map[string]snstypes.MessageAttributeValue) (bool, error) {
+	if p.err != nil {
+		return false, p.err
+	}
+
+	if p.scope == FilterPolicyScopeMessageBody {
+		var doc map[string]interface{}
+		if err := json.Unmarshal([]byte(body), &doc); err != nil {
+			return false, nil
+		}
+		return matchFilterPolicy(p.root, doc), nil
+	}
+
+	doc := make(map[string]interface{}, len(attributes))
+	for name, a := range attributes {
+		base, _, _ := strings.Cut(aws.ToString(a.DataType), ".")
+		switch base {
+		case AttributeDataTypeString:
+			if aws.ToString(a.DataType) == "String.Array" {
+				var values []interface{}
+				if err := json.Unmarshal([]byte(aws.ToString(a.StringValue)), &values); err != nil {
+					return false, fmt.Errorf("json.Unmarshal(%s) : %w", name, err)
+				}
+				doc[name] = values
+				continue
+			}
+			doc[name] = aws.ToString(a.StringValue)
+		case AttributeDataTypeNumber:
+			f, err := strconv.ParseFloat(aws.ToString(a.StringValue), 64)
+			if err != nil {
+				return false, fmt.Errorf("strconv.ParseFloat(%s) : %w", name, err)
+			}
+			doc[name] = f
+		default:
+			// Binary attributes are present but match no value.
+			doc[name] = struct{}{}
+		}
+	}
+
+	return matchFilterPolicy(p.root, doc), nil
+}
+
+// matchFilterPolicy returns whether every key of the policy matches the document.
+func matchFilterPolicy(policy, doc map[string]interface{}) bool {
+	for key, value := range policy {
+		if key == filterPolicyOr {
+			if alternatives, ok := value.([]interface{}); ok {
+				if !matchAnyFilterPolicy(alternatives, doc) {
+					return false
+				}
+				continue
+			}
+		}
+
+		field, present := doc[key]
+		switch v := value.(type) {
+		case map[string]interface{}:
+			// A missing or non-object parent has no nested keys, which still matches exists:false.
+			nested, _ := field.(map[string]interface{})
+			if !matchFilterPolicy(v, nested) {
+				return false
+			}
+		case []interface{}:
+			if !matchFilterConditions(v, field, present) {
+				return false
+			}
+		}
+	}
+
+	return true
+}
+
+// matchAnyFilterPolicy returns whether one of the alternatives matches the document.
+func matchAnyFilterPolicy(alternatives []interface{}, doc map[string]interface{}) bool {
+	for _, alt := range alternatives {
+		if m, ok := alt.(map[string]interface{}); ok && matchFilterPolicy(m, doc) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// matchFilterConditions returns whether one of the conditions matches the field, or one of its elements if it is an array.
+func matchFilterConditions(conditions []interface{}, field interface{}, present bool) bool {
+	values, ok := field.([]interface{})
+	if !ok {
+		values = []interface{}{field}
+	}
+
+	for _, c := range conditions {
+		if operator, ok := c.(map[string]interface{}); ok {
+			if exists, ok := operator["exists"].(bool); ok {
+				if exists == present {
+					return true
+				}
+				continue
+			}
+		}
+		if !present {
+			continue
+		}
+		for _, v := range values {
+			if matchFilterCondition(c, v) {
+				return true
+			}
+		}
+	}
+
+	return false
+}
+
+// matchFilterCondition returns whether a condition other than exists matches a value.
+func matchFilterCondition(condition, value interface{}) bool {
+	operator, ok := condition.(map[string]interface{})
+	if !ok {
+		return equalFilterScalars(condition, value)
+	}
+
+	for name, arg := range operator {
+		switch name {
+		case "prefix":
+			s, ok := value.(string)
+			return ok && strings.HasPrefix(s, fmt.Sprint(arg))
+		case "suffix":
+			s, ok := value.(string)
+			return ok && strings.HasSuffix(s, fmt.Sprint(arg))
+		case "equals-ignore-case":
+			s, ok := value.(string)
+			return ok && strings.EqualFold(s, fmt.Sprint(arg))
+		case "anything-but":
+			switch a := arg.(type) {
+			case []interface{}:
+				if !isFilterScalar(value) {
+					return false
+				}
+				for _, excluded := range a {
+					if equalFilterScalars(excluded, value) {
+						return false
+					}
+				}
+				return true
+			case map[string]interface{}:
+				s, ok := value.(string)
+				if prefix, isPrefix := a["prefix"].(string); isPrefix {
+					return ok && !strings.HasPrefix(s, prefix)
+				}
+				return false
+			default:
+				return isFilterScalar(value) && !equalFilterScalars(arg, value)
+			}
+		case "numeric":
+			n, ok := value.(float64)
+			comparisons, isList := arg.([]interface{})
+			return ok && isList && matchNumeric(comparisons, n)
+		}
+	}
+
+	return false
+}
+
+// matchNumeric returns whether the number satisfies every operator and bound pair of a numeric condition.
+func matchNumeric(comparisons []interface{}, n float64) bool {
+	if len(comparisons)%2 != 0 {
+		return false
+	}
+
+	for i := 0; i < len(comparisons); i += 2 {
+		operator, _ := comparisons[i].(string)
+		bound, ok := comparisons[i+1].(float64)
+		if !ok {
+			return false
+		}
+		var satisfied bool
+		switch operator {
+		case "=":
+			satisfied = n == bound
+		case "<":
+			satisfied = n < bound
+		case "<=":
+			satisfied = n <= bound
+		case ">":
+			satisfied = n > bound
+		case ">=":
+			satisfied = n >= bound
+		}
+		if !satisfied {
+			return false
+		}
+	}
+
+	return true
+}
@@ -0,0 +1,473 @@
+package pubsub
+
+import (
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	snstypes "github.com/aws/aws-sdk-go-v2/service/sns/types"
+)
+
+func stringAttribute(value string) snstypes.MessageAttributeValue {
+	return snstypes.MessageAttributeValue{DataType: aws.String(AttributeDataTypeString), StringValue: aws.String(value)}
+}
+
+func numberAttribute(value string) snstypes.MessageAttributeValue {
+	return snstypes.MessageAttributeValue{DataType: aws.String(AttributeDataTypeNumber), StringValue: aws.String(value)}
+}
+
+func arrayAttribute(value string) snstypes.MessageAttributeValue {
+	return snstypes.MessageAttributeValue{DataType: aws.String("String.Array"), StringValue: aws.String(value)}
+}
+
+func binaryAttribute(value []byte) snstypes.MessageAttributeValue {
+	return snstypes.MessageAttributeValue{DataType: aws.String(AttributeDataTypeBinary), BinaryValue: value}
+}
+
+func TestFilterPolicyJSON(t *testing.T) {
+	tests := []struct {
+		name   string
+		policy *FilterPolicy
+		want   string
+	}{
+		{
+			name:   "equals",
+			policy: NewFilterPolicy().Where("color", Equals("red"), Equals(1), Equals(int64(2)), Equals(1.5), Equals(true)),
+			want:   `{"color":["red",1,2,1.5,true]}`,
+		},
+		{
+			name:   "equals null",
+			policy: NewBodyFilterPolicy().Where("color", Equals(nil)),
+			want:   `{"color":[null]}`,
+		},
+		{
+			name:   "prefix",
+			policy: NewFilterPolicy().Where("color", Prefix("re")),
+			want:   `{"color":[{"prefix":"re"}]}`,
+		},
+		{
+			name:   "suffix",
+			policy: NewFilterPolicy().Where("color", Suffix("ed")),
+			want:   `{"color":[{"suffix":"ed"}]}`,
+		},
+		{
+			name:   "equals-ignore-case",
+			policy: NewFilterPolicy().Where("color", EqualsIgnoreCase("Red")),
+			want:   `{"color":[{"equals-ignore-case":"Red"}]}`,
+		},
+		{
+			name:   "anything-but",
+			policy: NewFilterPolicy().Where("color", AnythingBut("red", 1)),
+			want:   `{"color":[{"anything-but":["red",1]}]}`,
+		},
+		{
+			name:   "anything-but prefix",
+			policy: NewFilterPolicy().Where("color", AnythingButPrefix("re")),
+			want:   `{"color":[{"anything-but":{"prefix":"re"}}]}`,
+		},
+		{
+			name:   "numeric",
+			policy: NewFilterPolicy().Where("price", Numeric(">=", 10)),
+			want:   `{"price":[{"numeric":[">=",10]}]}`,
+		},
+		{
+			name:   "numeric range",
+			policy: NewFilterPolicy().Where("price", NumericRange(">", 0, "<=", 100)),
+			want:   `{"price":[{"numeric":[">",0,"<=",100]}]}`,
+		},
+		{
+			name:   "exists",
+			policy: NewFilterPolicy().Where("color", Exists(false)),
+			want:   `{"color":[{"exists":false}]}`,
+		},
+		{
+			name:   "nested",
+			policy: NewBodyFilterPolicy().Nested("order", NewBodyFilterPolicy().Where("status", Equals("paid"))),
+			want:   `{"order":{"status":["paid"]}}`,
+		},
+		{
+			name: "or",
+			policy: NewFilterPolicy().Where("source", Equals("shop")).Or(
+				NewFilterPolicy().Where("color", Equals("red")),
+				NewFilterPolicy().Where("price", Numeric(">", 100)),
+			),
+			want: `{"$or":[{"color":["red"]},{"price":[{"numeric":[">",100]}]}],"source":["shop"]}`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := tt.policy.JSON()
+			if err != nil {
+				t.Fatalf("JSON: %v", err)
+			}
+			if got != tt.want {
+				t.Errorf("JSON = %s, want %s", got, tt.want)
+			}
+
+			parsed, err := ParseFilterPolicy(got, tt.policy.Scope())
+			if err != nil {
+				t.Fatalf("ParseFilterPolicy: %v", err)
+			}
+			if reparsed, _ := parsed.JSON(); reparsed != got {
+				t.Errorf("parsed JSON = %s, want %s", reparsed, got)
+			}
+		})
+	}
+}
+
+func TestFilterPolicyErrors(t *testing.T) {
+	tests := []struct {
+		name   string
+		policy *FilterPolicy
+	}{
+		{name: "no conditions", policy: NewFilterPolicy().Where("color")},
+		{name: "unsupported value", policy: NewFilterPolicy().Where("color", Equals([]string{"red"}))},
+		{name: "anything-but bool", policy: NewFilterPolicy().Where("color", AnythingBut(true))},
+		{name: "unknown numeric operator", policy: NewFilterPolicy().Where("price", Numeric("!=", 1))},
+		{name: "lower bound operator", policy: NewFilterPolicy().Where("price", NumericRange("<", 0, "<", 1))},
+		{name: "upper bound operator", policy: NewFilterPolicy().Where("price", NumericRange(">", 0, ">", 1))},
+		{name: "inverted range", policy: NewFilterPolicy().Where("price", NumericRange(">", 2, "<", 1))},
+		{name: "key set twice", policy: NewFilterPolicy().Where("color", Equals("red")).Where("color", Equals("blue"))},
+		{name: "nested attributes", policy: NewFilterPolicy().Nested("order", NewBodyFilterPolicy())},
+		{name: "single alternative", policy: NewFilterPolicy().Or(NewFilterPolicy())},
+		{name: "invalid alternative", policy: NewFilterPolicy().Or(NewFilterPolicy(), NewFilterPolicy().Where("color"))},
+		{name: "alternative of another scope", policy: NewFilterPolicy().Or(NewFilterPolicy(), NewBodyFilterPolicy())},
+		{name: "nested of another scope", policy: NewBodyFilterPolicy().Nested("order", NewFilterPolicy())},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if tt.policy.Err() == nil {
+				t.Fatal("Err = nil, want an error")
+			}
+			if _, err := tt.policy.JSON(); err == nil {
+				t.Error("JSON error = nil, want an error")
+			}
+			if _, err := tt.policy.Match("{}", nil); err == nil {
+				t.Error("Match error = nil, want an error")
+			}
+		})
+	}
+}
+
+func TestParseFilterPolicyErrors(t *testing.T) {
+	tests := []struct {
+		name   string
+		policy string
+		scope  string
+	}{
+		{name: "unknown scope", policy: `{}`, scope: "Headers"},
+		{name: "not json", policy: `{`},
+		{name: "scalar key", policy: `{"color":"red"}`},
+		{name: "nested attributes", policy: `{"order":{"status":["paid"]}}`},
+		{name: "nested array", policy: `{"a":[[1]]}`, scope: FilterPolicyScopeMessageBody},
+		{name: "operator with two keys", policy: `{"a":[{"prefix":"a","suffix":"b"}]}`},
+		{name: "unknown operator", policy: `{"a":[{"contains":"a"}]}`},
+		{name: "prefix number", policy: `{"a":[{"prefix":1}]}`},
+		{name: "exists string", policy: `{"a":[{"exists":"true"}]}`},
+		{name: "anything-but object", policy: `{"a":[{"anything-but":{"suffix":"a"}}]}`},
+		{name: "anything-but nested list", policy: `{"a":[{"anything-but":[["a"]]}]}`},
+		{name: "numeric odd", policy: `{"a":[{"numeric":[">"]}]}`},
+		{name: "numeric operator", policy: `{"a":[{"numeric":["!=",1]}]}`},
+		{name: "numeric bound", policy: `{"a":[{"numeric":[">","1"]}]}`},
+		{name: "or not objects", policy: `{"$or":[["a"]]}`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := ParseFilterPolicy(tt.policy, tt.scope); err == nil {
+				t.Errorf("ParseFilterPolicy(%s) error = nil, want an error", tt.policy)
+			}
+		})
+	}
+}
+
+func TestFilterPolicyMatchAttributes(t *testing.T) {
+	tests := []struct {
+		name       string
+		policy     *FilterPolicy
+		attributes map[string]snstypes.MessageAttributeValue
+		want       bool
+	}{
+		{
+			name:       "equals",
+			policy:     NewFilterPolicy().Where("color", Equals("red"), Equals("blue")),
+			attributes: map[string]snstypes.MessageAttributeValue{"color": stringAttribute("blue")},
+			want:       true,
+		},
+		{
+			name:       "equals other",
+			policy:     NewFilterPolicy().Where("color", Equals("red")),
+			attributes: map[string]snstypes.MessageAttributeValue{"color": stringAttribute("green")},
+			want:       false,
+		},
+		{
+			name:   "missing key",
+			policy: NewFilterPolicy().Where("color", Equals("red")),
+			want:   false,
+		},
+		{
+			name:       "every key",
+			policy:     NewFilterPolicy().Where("color", Equals("red")).Where("size", Equals("xl")),
+			attributes: map[string]snstypes.MessageAttributeValue{"color": stringAttribute("red")},
+			want:       false,
+		},
+		{
+			name:       "number equals",
+			policy:     NewFilterPolicy().Where("price", Equals(10)),
+			attributes: map[string]snstypes.MessageAttributeValue{"price": numberAttribute("10.0")},
+			want:       true,
+		},
+		{
+			name:       "number is not a string",
+			policy:     NewFilterPolicy().Where("price", Equals("10")),
+			attributes: map[string]snstypes.MessageAttributeValue{"price": numberAttribute("10")},
+			want:       false,
+		},
+		{
+			name:       "prefix",
+			policy:     NewFilterPolicy().Where("color", Prefix("re")),
+			attributes: map[string]snstypes.MessageAttributeValue{"color": stringAttribute("red")},
+			want:       true,
+		},
+		{
+			name:       "suffix",
+			policy:     NewFilterPolicy().Where("color", Suffix("ue")),
+			attributes: map[string]snstypes.MessageAttributeValue{"color": stringAttribute("red")},
+			want:       false,
+		},
+		{
+			name:       "equals-ignore-case",
+			policy:     NewFilterPolicy().Where("color", EqualsIgnoreCase("RED")),
+			attributes: map[string]snstypes.MessageAttributeValue{"color": stringAttribute("Red")},
+			want:       true,
+		},
+		{
+			name:       "anything-but",
+			policy:     NewFilterPolicy().Where("color", AnythingBut("red", "blue")),
+			attributes: map[string]snstypes.MessageAttributeValue{"color": stringAttribute("green")},
+			want:       true,
+		},
+		{
+			name:       "anything-but excluded",
+			policy:     NewFilterPolicy().Where("color", AnythingBut("red", "blue")),
+			attributes: map[string]snstypes.MessageAttributeValue{"color": stringAttribute("blue")},
+			want:       false,
+		},
+		{
+			name:   "anything-but missing key",
+			policy: NewFilterPolicy().Where("color", AnythingBut("red")),
+			want:   false,
+		},
+		{
+			name:       "anything-but array with another element",
+			policy:     NewFilterPolicy().Where("colors", AnythingBut("red")),
+			attributes: map[string]snstypes.MessageAttributeValue{"colors": arrayAttribute(`["red","blue"]`)},
+			want:       true,
+		},
+		{
+			name:       "anything-but array of excluded elements",
+			policy:     NewFilterPolicy().Where("colors", AnythingBut("red", "blue")),
+			attributes: map[string]snstypes.MessageAttributeValue{"colors": arrayAttribute(`["red","blue"]`)},
+			want:       false,
+		},
+		{
+			name:       "anything-but prefix",
+			policy:     NewFilterPolicy().Where("color", AnythingButPrefix("re")),
+			attributes: map[string]snstypes.MessageAttributeValue{"color": stringAttribute("red")},
+			want:       false,
+		},
+		{
+			name:       "array element",
+			policy:     NewFilterPolicy().Where("colors", Equals("blue")),
+			attributes: map[string]snstypes.MessageAttributeValue{"colors": arrayAttribute(`["red","blue"]`)},
+			want:       true,
+		},
+		{
+			name:       "numeric",
+			policy:     NewFilterPolicy().Where("price", Numeric("<", 10)),
+			attributes: map[string]snstypes.MessageAttributeValue{"price": numberAttribute("9.5")},
+			want:       true,
+		},
+		{
+			name:       "numeric string",
+			policy:     NewFilterPolicy().Where("price", Numeric("<", 10)),
+			attributes: map[string]snstypes.MessageAttributeValue{"price": stringAttribute("9")},
+			want:       false,
+		},
+		{
+			name:       "numeric range lower bound",
+			policy:     NewFilterPolicy().Where("price", NumericRange(">=", 0, "<", 100)),
+			attributes: map[string]snstypes.MessageAttributeValue{"price": numberAttribute("0")},
+			want:       true,
+		},
+		{
+			name:       "numeric range upper bound",
+			policy:     NewFilterPolicy().Where("price", NumericRange(">=", 0, "<", 100)),
+			attributes: map[string]snstypes.MessageAttributeValue{"price": numberAttribute("100")},
+			want:       false,
+		},
+		{
+			name:       "exists",
+			policy:     NewFilterPolicy().Where("color", Exists(true)),
+			attributes: map[string]snstypes.MessageAttributeValue{"color": stringAttribute("")},
+			want:       true,
+		},
+		{
+			name:   "exists false",
+			policy: NewFilterPolicy().Where("color", Exists(false)),
+			want:   true,
+		},
+		{
+			name:       "exists false present",
+			policy:     NewFilterPolicy().Where("color", Exists(false)),
+			attributes: map[string]snstypes.MessageAttributeValue{"color": stringAttribute("red")},
+			want:       false,
+		},
+		{
+			name:       "binary exists",
+			policy:     NewFilterPolicy().Where("payload", Exists(true)),
+			attributes: map[string]snstypes.MessageAttributeValue{"payload": binaryAttribute([]byte("red"))},
+			want:       true,
+		},
+		{
+			name:       "binary equals",
+			policy:     NewFilterPolicy().Where("payload", Equals("red")),
+			attributes: map[string]snstypes.MessageAttributeValue{"payload": binaryAttribute([]byte("red"))},
+			want:       false,
+		},
+		{
+			name:       "binary anything-but",
+			policy:     NewFilterPolicy().Where("payload", AnythingBut("red")),
+			attributes: map[string]snstypes.MessageAttributeValue{"payload": binaryAttribute([]byte("blue"))},
+			want:       false,
+		},
+		{
+			name: "or",
+			policy: NewFilterPolicy().Where("source", Equals("shop")).Or(
+				NewFilterPolicy().Where("color", Equals("red")),
+				NewFilterPolicy().Where("price", Numeric(">", 100)),
+			),
+			attributes: map[string]snstypes.MessageAttributeValue{"source": stringAttribute("shop"), "price": numberAttribute("150")},
+			want:       true,
+		},
+		{
+			name: "or without alternative",
+			policy: NewFilterPolicy().Where("source", Equals("shop")).Or(
+				NewFilterPolicy().Where("color", Equals("red")),
+				NewFilterPolicy().Where("price", Numeric(">", 100)),
+			),
+			attributes: map[string]snstypes.MessageAttributeValue{"source": stringAttribute("shop"), "price": numberAttribute("50")},
+			want:       false,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := tt.policy.Match("", tt.attributes)
+			if err != nil {
+				t.Fatalf("Match: %v", err)
+			}
+			if got != tt.want {
+				t.Errorf("Match = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestFilterPolicyMatchBody(t *testing.T) {
+	tests := []struct {
+		name   string
+		policy *FilterPolicy
+		body   string
+		want   bool
+	}{
+		{
+			name:   "equals",
+			policy: NewBodyFilterPolicy().Where("color", Equals("red")),
+			body:   `{"color":"red"}`,
+			want:   true,
+		},
+		{
+			name:   "not an object",
+			policy: NewBodyFilterPolicy().Where("color", Exists(false)),
+			body:   `red`,
+			want:   false,
+		},
+		{
+			name:   "null",
+			policy: NewBodyFilterPolicy().Where("color", Equals(nil)),
+			body:   `{"color":null}`,
+			want:   true,
+		},
+		{
+			name:   "boolean",
+			policy: NewBodyFilterPolicy().Where("paid", Equals(true)),
+			body:   `{"paid":false}`,
+			want:   false,
+		},
+		{
+			name:   "nested",
+			policy: NewBodyFilterPolicy().Nested("order", NewBodyFilterPolicy().Where("status", Equals("paid"))),
+			body:   `{"order":{"status":"paid"}}`,
+			want:   true,
+		},
+		{
+			name:   "nested not an object",
+			policy: NewBodyFilterPolicy().Nested("order", NewBodyFilterPolicy().Where("status", Equals("paid"))),
+			body:   `{"order":"paid"}`,
+			want:   false,
+		},
+		{
+			name:   "nested exists false",
+			policy: NewBodyFilterPolicy().Nested("order", NewBodyFilterPolicy().Where("coupon", Exists(false))),
+			body:   `{"order":{"status":"paid"}}`,
+			want:   true,
+		},
+		{
+			name:   "nested exists false without parent",
+			policy: NewBodyFilterPolicy().Nested("order", NewBodyFilterPolicy().Where("coupon", Exists(false))),
+			body:   `{"color":"red"}`,
+			want:   true,
+		},
+		{
+			name:   "nested equals without parent",
+			policy: NewBodyFilterPolicy().Nested("order", NewBodyFilterPolicy().Where("status", Equals("paid"))),
+			body:   `{"color":"red"}`,
+			want:   false,
+		},
+		{
+			name:   "array of objects",
+			policy: NewBodyFilterPolicy().Where("items", Equals("red")),
+			body:   `{"items":[{"color":"red"},[1]]}`,
+			want:   false,
+		},
+		{
+			name:   "anything-but object",
+			policy: NewBodyFilterPolicy().Where("order", AnythingBut("paid")),
+			body:   `{"order":{"status":"paid"}}`,
+			want:   false,
+		},
+		{
+			name: "or",
+			policy: NewBodyFilterPolicy().Or(
+				NewBodyFilterPolicy().Where("color", Equals("red")),
+				NewBodyFilterPolicy().Nested("order", NewBodyFilterPolicy().Where("total", Numeric(">=", 100))),
+			),
+			body: `{"color":"blue","order":{"total":100}}`,
+			want: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := tt.policy.Match(tt.body, nil)
+			if err != nil {
+				t.Fatalf("Match: %v", err)
+			}
+			if got != tt.want {
+				t.Errorf("Match = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,66 @@
+package pubsub
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// DOT returns the topology as a Graphviz digraph, with topics as boxes, queues as ellipses,
+// subscriptions as edges from topics to queues and redrive policies as dashed edges to dead-letter queues.
+func (t *Topology) DOT() string {
+	var b strings.Builder
+	b.WriteString("digraph topology {\n\trankdir=LR;\n")
+	for _, topic := range t.Topics {
+		fmt.Fprintf(&b, "\t%s [label=%s, shape=box];\n", strconv.Quote("topic:"+topic.Name), strconv.Quote(topic.Name))
+	}
+	for _, queue := range t.Queues {
+		fmt.Fprintf(&b, "\t%s [label=%s, shape=ellipse];\n", strconv.Quote("queue:"+queue.Name), strconv.Quote(queue.Name))
+	}
+	for _, s := range t.Subscriptions {
+		fmt.Fprintf(&b, "\t%s -> %s;\n", strconv.Quote("topic:"+s.Topic), strconv.Quote("queue:"+s.Queue))
+	}
+	for _, queue := range t.Queues {
+		if queue.DeadLetterQueue == "" {
+			continue
+		}
+		fmt.Fprintf(&b, "\t%s -> %s [label=%s, style=dashed];\n",
+			strconv.Quote("queue:"+queue.Name), strconv.Quote("queue:"+queue.DeadLetterQueue),
+			strconv.Quote(fmt.Sprintf("maxReceiveCount=%d", queue.maxReceiveCount())))
+	}
+	b.WriteString("}\n")
+
+	return b.String()
+}
+
+// Mermaid returns the topology as a Mermaid flowchart, drawn like DOT.
+func (t *Topology) Mermaid() string {
+	ids := make(map[string]string, len(t.Topics)+len(t.Queues))
+	var b strings.Builder
+	b.WriteString("flowchart LR\n")
+	for i, topic := range t.Topics {
+		ids["topic:"+topic.Name] = fmt.Sprintf("t%d", i)
+		fmt.Fprintf(&b, "    t%d[%s]\n", i, mermaidLabel(topic.Name))
+	}
+	for i, queue := range t.Queues {
+		ids["queue:"+queue.Name] = fmt.Sprintf("q%d", i)
+		fmt.Fprintf(&b, "    q%d([%s])\n", i, mermaidLabel(queue.Name))
+	}
+	for _, s := range t.Subscriptions {
+		fmt.Fprintf(&b, "    %s --> %s\n", ids["topic:"+s.Topic], ids["queue:"+s.Queue])
+	}
+	for _, queue := range t.Queues {
+		if queue.DeadLetterQueue == "" {
+			continue
+		}
+		fmt.Fprintf(&b, "    %s -. maxReceiveCount=%d .-> %s\n",
+			ids["queue:"+queue.Name], queue.maxReceiveCount(), ids["queue:"+queue.DeadLetterQueue])
+	}
+
+	return b.String()
+}
+
+// mermaidLabel quotes a node label, escaping the quotes Mermaid does not accept in labels.
+func mermaidLabel(s string) string {
+	return `"` + strings.ReplaceAll(s, `"`, "#quot;") + `"`
+}
@@ -0,0 +1,182 @@
+package pubsub
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/aws/arn"
+	"github.com/aws/aws-sdk-go-v2/service/sns"
+	snstypes "github.com/aws/aws-sdk-go-v2/service/sns/types"
+	"github.com/aws/aws-sdk-go-v2/service/sqs"
+)
+
+// maxListQueuesResults is the page size of ListQueues. SQS only paginates when a page size is set,
+// and otherwise returns at most 1000 queues.
+const maxListQueuesResults = 1000
+
+// Iterator iterates over the items of a paginated listing, fetching the pages as they are needed.
+//
+//	it := client.ListTopics()
+//	for it.Next(ctx) {
+//		topic := it.Value()
+//	}
+//	if err := it.Err(); err != nil {
+type Iterator[T any] struct {
+	hasMorePages func() bool
+	nextPage     func(ctx context.Context) ([]T, error)
+	page         []T
+	value        T
+	err          error
+}
+
+// Next advances to the next item, fetching the next page if needed, and returns false at the end or on error.
+func (it *Iterator[T]) Next(ctx context.Context) bool {
+	for len(it.page) == 0 {
+		if it.err != nil || !it.hasMorePages() {
+			return false
+		}
+		it.page, it.err = it.nextPage(ctx)
+	}
+	it.value, it.page = it.page[0], it.page[1:]
+
+	return true
+}
+
+// Value returns the current item.
+func (it *Iterator[T]) Value() T {
+	return it.value
+}
+
+// Err returns the error that stopped the iteration, if any.
+func (it *Iterator[T]) Err() error {
+	return it.err
+}
+
+// All returns the remaining items.
+func (it *Iterator[T]) All(ctx context.Context) ([]T, error) {
+	var items []T
+	for it.Next(ctx) {
+		items = append(items, it.Value())
+	}
+
+	return items, it.Err()
+}
+
+// SubscriptionSummary is a subscription as listed by SNS, of any protocol.
+type SubscriptionSummary struct {
+	SubscriptionArn string
+	TopicArn        string
+	Protocol        string
+	Endpoint        string
+	Owner           string
+}
+
+// newSubscriptionSummaries converts listed subscriptions.
+func newSubscriptionSummaries(subscriptions []snstypes.Subscription) []SubscriptionSummary {
+	out := make([]SubscriptionSummary, 0, len(subscriptions))
+	for _, s := range subscriptions {
+		out = append(out, SubscriptionSummary{
+			SubscriptionArn: aws.ToString(s.SubscriptionArn),
+			TopicArn:        aws.ToString(s.TopicArn),
+			Protocol:        aws.ToString(s.Protocol),
+			Endpoint:        aws.ToString(s.Endpoint),
+			Owner:           aws.ToString(s.Owner),
+		})
+	}
+
+	return out
+}
+
+// ListTopics returns an iterator over the topics of the account.
+func (c *PubsubClient) ListTopics() *Iterator[*Topic] {
+	paginator := sns.NewListTopicsPaginator(c.SNS, &sns.ListTopicsInput{})
+
+	return &Iterator[*Topic]{
+		hasMorePages: paginator.HasMorePages,
+		nextPage: func(ctx context.Context) ([]*Topic, error) {
+			page, err := paginator.NextPage(ctx)
+			if err != nil {
+				return nil, fmt.Errorf("paginator.NextPage: %w", err)
+			}
+
+			topics := make([]*Topic, 0, len(page.Topics))
+			for _, t := range page.Topics {
+				topicArn := aws.ToString(t.TopicArn)
+				parsed, err := arn.Parse(topicArn)
+				if err != nil {
+					return nil, fmt.Errorf("arn.Parse(%s) : %w", topicArn, err)
+				}
+				topics = append(topics, &Topic{client: c, topicName: parsed.Resource, topicArn: topicArn})
+			}
+			return topics, nil
+		},
+	}
+}
+
+// ListQueues returns an iterator over the queues whose name starts with the prefix, or every queue if it is empty.
+// The arn of each queue is fetched with one call per queue.
+func (c *PubsubClient) ListQueues(prefix string) *Iterator[*Queue] {
+	input := &sqs.ListQueuesInput{MaxResults: aws.Int32(maxListQueuesResults)}
+	if prefix != "" {
+		input.QueueNamePrefix = aws.String(prefix)
+	}
+	paginator := sqs.NewListQueuesPaginator(c.SQS, input)
+
+	return &Iterator[*Queue]{
+		hasMorePages: paginator.HasMorePages,
+		nextPage: func(ctx context.Context) ([]*Queue, error) {
+			page, err := paginator.NextPage(ctx)
+			if err != nil {
+				return nil, fmt.Errorf("paginator.NextPage: %w", err)
+			}
+
+			queues := make([]*Queue, 0, len(page.QueueUrls))
+			for _, queueUrl := range page.QueueUrls {
+				queue, err := c.newQueueFromUrl(ctx, queueUrl)
+				if err != nil {
+					return nil, fmt.Errorf("c.newQueueFromUrl(%s) : %w", queueUrl, err)
+				}
+				queues = append(queues, queue)
+			}
+			return queues, nil
+		},
+	}
+}
+
+// ListSubscriptions returns an iterator over the subscriptions of the account.
+func (c *PubsubClient) ListSubscriptions() *Iterator[SubscriptionSummary] {
+	paginator := sns.NewListSubscriptionsPaginator(c.SNS, &sns.ListSubscriptionsInput{})
+
+	return &Iterator[SubscriptionSummary]{
+		hasMorePages: paginator.HasMorePages,
+		nextPage: func(ctx context.Context) ([]SubscriptionSummary, error) {
+			page, err := paginator.NextPage(ctx)
+			if err != nil {
+				return nil, fmt.Errorf("paginator.NextPage: %w", err)
+			}
+			return newSubscriptionSummaries(page.Subscriptions), nil
+		},
+	}
+}
+
+// ListSubscriptionsByTopic returns an iterator over the subscriptions of the topic.
+func (c *PubsubClient) ListSubscriptionsByTopic(topicArn string) *Iterator[SubscriptionSummary] {
+	paginator := sns.NewListSubscriptionsByTopicPaginator(c.SNS, &sns.ListSubscriptionsByTopicInput{TopicArn: aws.String(topicArn)})
+
+	return &Iterator[SubscriptionSummary]{
+		hasMorePages: paginator.HasMorePages,
+		nextPage: func(ctx context.Context) ([]SubscriptionSummary, error) {
+			page, err := paginator.NextPage(ctx)
+			if err != nil {
+				return nil, fmt.Errorf("paginator.NextPage: %w", err)
+			}
+			return newSubscriptionSummaries(page.Subscriptions), nil
+		},
+	}
+}
+
+// Subscriptions returns an iterator over the subscriptions of the topic.
+func (t *Topic) Subscriptions() *Iterator[SubscriptionSummary] {
+	return t.client.ListSubscriptionsByTopic(t.topicArn)
+}
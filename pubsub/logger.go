@@ -0,0 +1,138 @@
+package pubsub
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"strings"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/sqs/types"
+)
+
+// LogLevel is the severity of a log entry. The values are the same as those of log/slog.
+type LogLevel int
+
+const (
+	LevelDebug LogLevel = -4
+	LevelInfo  LogLevel = 0
+	LevelWarn  LogLevel = 4
+	LevelError LogLevel = 8
+)
+
+// String returns the name of the level.
+func (l LogLevel) String() string {
+	switch {
+	case l < LevelInfo:
+		return "DEBUG"
+	case l < LevelWarn:
+		return "INFO"
+	case l < LevelError:
+		return "WARN"
+	}
+
+	return "ERROR"
+}
+
+// Logger is the structured logger used by the package. *slog.Logger satisfies it.
+// args are alternating keys and values.
+type Logger interface {
+	DebugContext(ctx context.Context, msg string, args ...any)
+	InfoContext(ctx context.Context, msg string, args ...any)
+	WarnContext(ctx context.Context, msg string, args ...any)
+	ErrorContext(ctx context.Context, msg string, args ...any)
+}
+
+// nopLogger discards every entry.
+type nopLogger struct{}
+
+func (nopLogger) DebugContext(context.Context, string, ...any) {}
+func (nopLogger) InfoContext(context.Context, string, ...any)  {}
+func (nopLogger) WarnContext(context.Context, string, ...any)  {}
+func (nopLogger) ErrorContext(context.Context, string, ...any) {}
+
+// StdLogger is a Logger that writes key=value lines to a *log.Logger, dropping entries below Level.
+type StdLogger struct {
+	Logger *log.Logger
+	Level  LogLevel
+}
+
+// NewStdLogger returns a StdLogger. If l is nil, log.Default() is used.
+func NewStdLogger(l *log.Logger, level LogLevel) *StdLogger {
+	if l == nil {
+		l = log.Default()
+	}
+
+	return &StdLogger{Logger: l, Level: level}
+}
+
+// DebugContext logs at LevelDebug.
+func (l *StdLogger) DebugContext(_ context.Context, msg string, args ...any) {
+	l.log(LevelDebug, msg, args)
+}
+
+// InfoContext logs at LevelInfo.
+func (l *StdLogger) InfoContext(_ context.Context, msg string, args ...any) {
+	l.log(LevelInfo, msg, args)
+}
+
+// WarnContext logs at LevelWarn.
+func (l *StdLogger) WarnContext(_ context.Context, msg string, args ...any) {
+	l.log(LevelWarn, msg, args)
+}
+
+// ErrorContext logs at LevelError.
+func (l *StdLogger) ErrorContext(_ context.Context, msg string, args ...any) {
+	l.log(LevelError, msg, args)
+}
+
+// log formats the entry as level=... msg=... key=value.
+func (l *StdLogger) log(level LogLevel, msg string, args []any) {
+	if level < l.Level {
+		return
+	}
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "level=%s msg=%q", level, msg)
+	for i := 0; i < len(args); i += 2 {
+		if i+1 == len(args) {
+			fmt.Fprintf(&b, " !BADKEY=%v", args[i])
+			break
+		}
+		fmt.Fprintf(&b, " %v=%q", args[i], fmt.Sprint(args[i+1]))
+	}
+	l.Logger.Print(b.String())
+}
+
+// logger returns the configured Logger, or one that discards every entry.
+func (c *PubsubClient) logger() Logger {
+	if c.Logger == nil {
+		return nopLogger{}
+	}
+
+	return c.Logger
+}
+
+// redact returns the form of a message body that may be logged.
+func (c *PubsubClient) redact(body string) string {
+	if c.RedactBody == nil {
+		return fmt.Sprintf("[REDACTED %d bytes]", len(body))
+	}
+
+	return c.RedactBody(body)
+}
+
+// logArgs returns the structured fields of a received message.
+func (q *Queue) logArgs(m types.Message) []any {
+	return []any{
+		"queue", q.queueName,
+		"message_id", aws.ToString(m.MessageId),
+		"receive_count", m.Attributes[string(types.MessageSystemAttributeNameApproximateReceiveCount)],
+	}
+}
+
+// logDecodeFailure logs a message whose body could not be decoded.
+func (q *Queue) logDecodeFailure(ctx context.Context, m types.Message, body string, err error) {
+	args := append(q.logArgs(m), "body", q.client.redact(body), "error", err)
+	q.client.logger().WarnContext(ctx, "failed to unmarshal json", args...)
+}
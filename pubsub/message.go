@@ -0,0 +1,82 @@
+package pubsub
+
+import (
+	"context"
+	"strconv"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/sqs/types"
+)
+
+// Message is a received message with its metadata.
+type Message struct {
+	ID            string
+	ReceiptHandle string
+	Body          string
+	// Attributes are the message attributes set by the sender.
+	Attributes map[string]types.MessageAttributeValue
+	// SystemAttributes are the message system attributes, e.g. SenderId or MessageGroupId.
+	SystemAttributes      map[string]string
+	SentTimestamp         time.Time
+	FirstReceiveTimestamp time.Time
+	ReceiveCount          int
+	QueueName             string
+	QueueArn              string
+	QueueUrl              string
+}
+
+// newMessage returns the Message of a message received from the queue.
+func newMessage(q *Queue, m types.Message) *Message {
+	msg := &Message{
+		ID:               aws.ToString(m.MessageId),
+		ReceiptHandle:    aws.ToString(m.ReceiptHandle),
+		Body:             aws.ToString(m.Body),
+		Attributes:       m.MessageAttributes,
+		SystemAttributes: m.Attributes,
+		QueueName:        q.queueName,
+		QueueArn:         q.queueArn,
+		QueueUrl:         q.queueUrl,
+	}
+	msg.SentTimestamp = parseEpochMillis(m.Attributes[string(types.MessageSystemAttributeNameSentTimestamp)])
+	msg.FirstReceiveTimestamp = parseEpochMillis(m.Attributes[string(types.MessageSystemAttributeNameApproximateFirstReceiveTimestamp)])
+	if n, err := strconv.Atoi(m.Attributes[string(types.MessageSystemAttributeNameApproximateReceiveCount)]); err == nil {
+		msg.ReceiveCount = n
+	}
+
+	return msg
+}
+
+// StringAttribute returns the string value of a message attribute.
+func (m *Message) StringAttribute(name string) (string, bool) {
+	v, ok := m.Attributes[name]
+	if !ok || v.StringValue == nil {
+		return "", false
+	}
+
+	return *v.StringValue, true
+}
+
+// parseEpochMillis parses a timestamp in milliseconds since the epoch, returning the zero time if it is invalid.
+func parseEpochMillis(s string) time.Time {
+	ms, err := strconv.ParseInt(s, 10, 64)
+	if err != nil {
+		return time.Time{}
+	}
+
+	return time.UnixMilli(ms)
+}
+
+// messageKey is the context key of the Message.
+type messageKey struct{}
+
+// contextWithMessage returns a copy of ctx carrying the message.
+func contextWithMessage(ctx context.Context, m *Message) context.Context {
+	return context.WithValue(ctx, messageKey{}, m)
+}
+
+// MessageFromContext returns the message being handled. It is available in the context passed to every handler.
+func MessageFromContext(ctx context.Context) (*Message, bool) {
+	m, ok := ctx.Value(messageKey{}).(*Message)
+	return m, ok
+}
@@ -0,0 +1,328 @@
+package pubsub
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Metrics receives the events of consumers and producers.
+// Implementations must be safe for concurrent use.
+type Metrics interface {
+	// Received is called after each ReceiveMessage call with the number of messages received.
+	Received(queue string, batchSize int)
+	// ReceiveFailed is called when ReceiveMessage fails.
+	ReceiveFailed(queue string)
+	// Handled is called after the handler returns.
+	Handled(queue string, duration time.Duration, err error)
+	// Acked is called when a message is deleted after it was handled successfully.
+	Acked(queue string)
+	// Requeued is called when a message is made visible again for a retry.
+	Requeued(queue string)
+	// Dropped is called when a message is deleted after a failure classified with Drop.
+	Dropped(queue string)
+	// DeadLettered is called when a message is forwarded to or left for the dead-letter queue after a failure.
+	DeadLettered(queue string)
+	// AckFailed is called when deleting a message or changing its visibility fails.
+	AckFailed(queue string)
+	// Sent is called after a message is sent to a queue.
+	Sent(queue string, err error)
+	// Published is called after a message is published to a topic.
+	Published(topic string, err error)
+}
+
+// nopMetrics discards every event.
+type nopMetrics struct{}
+
+func (nopMetrics) Received(string, int)                 {}
+func (nopMetrics) ReceiveFailed(string)                 {}
+func (nopMetrics) Handled(string, time.Duration, error) {}
+func (nopMetrics) Acked(string)                         {}
+func (nopMetrics) Requeued(string)                      {}
+func (nopMetrics) Dropped(string)                       {}
+func (nopMetrics) DeadLettered(string)                  {}
+func (nopMetrics) AckFailed(string)                     {}
+func (nopMetrics) Sent(string, error)                   {}
+func (nopMetrics) Published(string, error)              {}
+
+// metrics returns the configured Metrics, or one that discards every event.
+func (c *PubsubClient) metrics() Metrics {
+	if c.Metrics == nil {
+		return nopMetrics{}
+	}
+
+	return c.Metrics
+}
+
+var (
+	// DefaultDurationBuckets are the upper bounds in seconds of the handler duration histogram.
+	DefaultDurationBuckets = []float64{0.005, 0.01, 0.025, 0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10, 30, 60}
+	// DefaultBatchSizeBuckets are the upper bounds of the receive batch size histogram.
+	DefaultBatchSizeBuckets = []float64{0, 1, 2, 3, 4, 5, 6, 7, 8, 9, 10}
+)
+
+// PrometheusMetrics is a Metrics that keeps counters and histograms in memory and
+// exposes them in the Prometheus text format as an http.Handler.
+type PrometheusMetrics struct {
+	received      *counterVec
+	receiveErrors *counterVec
+	handled       *counterVec
+	acked         *counterVec
+	requeued      *counterVec
+	dropped       *counterVec
+	deadLettered  *counterVec
+	ackErrors     *counterVec
+	sent          *counterVec
+	published     *counterVec
+	duration      *histogramVec
+	batchSize     *histogramVec
+}
+
+// NewPrometheusMetrics returns a PrometheusMetrics with the default buckets.
+func NewPrometheusMetrics() *PrometheusMetrics {
+	return &PrometheusMetrics{
+		received:      newCounterVec("pubsub_messages_received_total", "Messages received from the queue.", "queue"),
+		receiveErrors: newCounterVec("pubsub_receive_errors_total", "Failed ReceiveMessage calls.", "queue"),
+		handled:       newCounterVec("pubsub_messages_handled_total", "Messages passed to the handler.", "queue", "result"),
+		acked:         newCounterVec("pubsub_messages_acked_total", "Messages deleted after successful handling.", "queue"),
+		requeued:      newCounterVec("pubsub_messages_requeued_total", "Messages made visible again for a retry.", "queue"),
+		dropped:       newCounterVec("pubsub_messages_dropped_total", "Messages deleted after a failure classified with Drop.", "queue"),
+		deadLettered:  newCounterVec("pubsub_messages_dead_lettered_total", "Messages forwarded to or left for the dead-letter queue after a failure.", "queue"),
+		ackErrors:     newCounterVec("pubsub_ack_errors_total", "Failed DeleteMessage and ChangeMessageVisibility calls.", "queue"),
+		sent:          newCounterVec("pubsub_messages_sent_total", "Messages sent to the queue.", "queue", "result"),
+		published:     newCounterVec("pubsub_messages_published_total", "Messages published to the topic.", "topic", "result"),
+		duration:      newHistogramVec("pubsub_handler_duration_seconds", "Duration of the handler.", DefaultDurationBuckets, "queue"),
+		batchSize:     newHistogramVec("pubsub_receive_batch_size", "Number of messages returned by ReceiveMessage.", DefaultBatchSizeBuckets, "queue"),
+	}
+}
+
+// Received implements Metrics.
+func (p *PrometheusMetrics) Received(queue string, batchSize int) {
+	p.received.add(float64(batchSize), queue)
+	p.batchSize.observe(float64(batchSize), queue)
+}
+
+// ReceiveFailed implements Metrics.
+func (p *PrometheusMetrics) ReceiveFailed(queue string) {
+	p.receiveErrors.add(1, queue)
+}
+
+// Handled implements Metrics.
+func (p *PrometheusMetrics) Handled(queue string, duration time.Duration, err error) {
+	p.handled.add(1, queue, result(err))
+	p.duration.observe(duration.Seconds(), queue)
+}
+
+// Acked implements Metrics.
+func (p *PrometheusMetrics) Acked(queue string) {
+	p.acked.add(1, queue)
+}
+
+// Requeued implements Metrics.
+func (p *PrometheusMetrics) Requeued(queue string) {
+	p.requeued.add(1, queue)
+}
+
+// Dropped implements Metrics.
+func (p *PrometheusMetrics) Dropped(queue string) {
+	p.dropped.add(1, queue)
+}
+
+// DeadLettered implements Metrics.
+func (p *PrometheusMetrics) DeadLettered(queue string) {
+	p.deadLettered.add(1, queue)
+}
+
+// AckFailed implements Metrics.
+func (p *PrometheusMetrics) AckFailed(queue string) {
+	p.ackErrors.add(1, queue)
+}
+
+// Sent implements Metrics.
+func (p *PrometheusMetrics) Sent(queue string, err error) {
+	p.sent.add(1, queue, result(err))
+}
+
+// Published implements Metrics.
+func (p *PrometheusMetrics) Published(topic string, err error) {
+	p.published.add(1, topic, result(err))
+}
+
+// WriteTo writes every metric in the Prometheus text format.
+func (p *PrometheusMetrics) WriteTo(w io.Writer) (int64, error) {
+	cw := &countingWriter{w: w}
+	for _, c := range []*counterVec{p.received, p.receiveErrors, p.handled, p.acked, p.requeued, p.dropped, p.deadLettered, p.ackErrors, p.sent, p.published} {
+		c.write(cw)
+	}
+	for _, h := range []*histogramVec{p.duration, p.batchSize} {
+		h.write(cw)
+	}
+
+	return cw.n, cw.err
+}
+
+// ServeHTTP writes every metric in the Prometheus text format.
+func (p *PrometheusMetrics) ServeHTTP(w http.ResponseWriter, _ *http.Request) {
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+	_, _ = p.WriteTo(w)
+}
+
+// result returns the value of the result label.
+func result(err error) string {
+	if err != nil {
+		return "error"
+	}
+
+	return "success"
+}
+
+// countingWriter counts the bytes written and keeps the first error.
+type countingWriter struct {
+	w   io.Writer
+	n   int64
+	err error
+}
+
+// printf writes a formatted string unless a previous write failed.
+func (cw *countingWriter) printf(format string, args ...interface{}) {
+	if cw.err != nil {
+		return
+	}
+	n, err := fmt.Fprintf(cw.w, format, args...)
+	cw.n += int64(n)
+	cw.err = err
+}
+
+// labelSeparator joins label values into a map key.
+const labelSeparator = "\xff"
+
+// labelValueReplacer escapes a label value for the Prometheus text format.
+var labelValueReplacer = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+
+// formatLabels returns the label pairs of a series, e.g. {queue="a",result="success"}.
+func formatLabels(names []string, key string, extra ...string) string {
+	values := strings.Split(key, labelSeparator)
+	pairs := make([]string, 0, len(names)+1)
+	for i, name := range names {
+		pairs = append(pairs, fmt.Sprintf(`%s="%s"`, name, labelValueReplacer.Replace(values[i])))
+	}
+	for i := 0; i+1 < len(extra); i += 2 {
+		pairs = append(pairs, fmt.Sprintf(`%s="%s"`, extra[i], labelValueReplacer.Replace(extra[i+1])))
+	}
+
+	return "{" + strings.Join(pairs, ",") + "}"
+}
+
+// formatFloat formats a sample value.
+func formatFloat(v float64) string {
+	return strconv.FormatFloat(v, 'g', -1, 64)
+}
+
+// counterVec is a counter partitioned by labels.
+type counterVec struct {
+	name   string
+	help   string
+	labels []string
+
+	mu     sync.Mutex
+	values map[string]float64
+}
+
+// newCounterVec returns a counter without series.
+func newCounterVec(name, help string, labels ...string) *counterVec {
+	return &counterVec{name: name, help: help, labels: labels, values: make(map[string]float64)}
+}
+
+// add increases the series of the label values by v.
+func (c *counterVec) add(v float64, labelValues ...string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.values[strings.Join(labelValues, labelSeparator)] += v
+}
+
+// write writes the counter in the Prometheus text format.
+func (c *counterVec) write(cw *countingWriter) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	cw.printf("# HELP %s %s\n# TYPE %s counter\n", c.name, c.help, c.name)
+	for _, key := range sortedKeys(c.values) {
+		cw.printf("%s%s %s\n", c.name, formatLabels(c.labels, key), formatFloat(c.values[key]))
+	}
+}
+
+// histogram is a single histogram series.
+type histogram struct {
+	counts []uint64
+	sum    float64
+	count  uint64
+}
+
+// histogramVec is a histogram partitioned by labels.
+type histogramVec struct {
+	name    string
+	help    string
+	labels  []string
+	buckets []float64
+
+	mu     sync.Mutex
+	values map[string]*histogram
+}
+
+// newHistogramVec returns a histogram without series.
+func newHistogramVec(name, help string, buckets []float64, labels ...string) *histogramVec {
+	return &histogramVec{name: name, help: help, labels: labels, buckets: buckets, values: make(map[string]*histogram)}
+}
+
+// observe adds v to the series of the label values.
+func (h *histogramVec) observe(v float64, labelValues ...string) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	key := strings.Join(labelValues, labelSeparator)
+	s, ok := h.values[key]
+	if !ok {
+		s = &histogram{counts: make([]uint64, len(h.buckets))}
+		h.values[key] = s
+	}
+	for i, upper := range h.buckets {
+		if v <= upper {
+			s.counts[i]++
+		}
+	}
+	s.sum += v
+	s.count++
+}
+
+// write writes the histogram in the Prometheus text format.
+func (h *histogramVec) write(cw *countingWriter) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	cw.printf("# HELP %s %s\n# TYPE %s histogram\n", h.name, h.help, h.name)
+	for _, key := range sortedKeys(h.values) {
+		s := h.values[key]
+		for i, upper := range h.buckets {
+			cw.printf("%s_bucket%s %d\n", h.name, formatLabels(h.labels, key, "le", formatFloat(upper)), s.counts[i])
+		}
+		cw.printf("%s_bucket%s %d\n", h.name, formatLabels(h.labels, key, "le", "+Inf"), s.count)
+		cw.printf("%s_sum%s %s\n", h.name, formatLabels(h.labels, key), formatFloat(s.sum))
+		cw.printf("%s_count%s %d\n", h.name, formatLabels(h.labels, key), s.count)
+	}
+}
+
+// sortedKeys returns the keys of m in order.
+func sortedKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	return keys
+}
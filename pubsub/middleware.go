@@ -0,0 +1,115 @@
+package pubsub
+
+import (
+	"context"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/sqs/types"
+)
+
+// MessageHandler handles a received message. A returned error is classified with Classify.
+// Every consume variant decodes the message inside a MessageHandler.
+type MessageHandler func(ctx context.Context, m types.Message) error
+
+// Middleware wraps a MessageHandler to add cross-cutting behavior.
+type Middleware func(next MessageHandler) MessageHandler
+
+// Chain composes the middlewares so that the first one is the outermost.
+func Chain(middlewares ...Middleware) Middleware {
+	return func(next MessageHandler) MessageHandler {
+		for i := len(middlewares) - 1; i >= 0; i-- {
+			next = middlewares[i](next)
+		}
+		return next
+	}
+}
+
+// Use appends middlewares applied to every consume variant of the queue,
+// inside the middlewares of the PubsubClient.
+func (q *Queue) Use(middlewares ...Middleware) *Queue {
+	q.middlewares = append(q.middlewares, middlewares...)
+	return q
+}
+
+// handler wraps f with the middlewares of the client and the queue.
+func (q *Queue) handler(f MessageHandler) MessageHandler {
+	middlewares := make([]Middleware, 0, len(q.client.Middlewares)+len(q.middlewares))
+	middlewares = append(middlewares, q.client.Middlewares...)
+	middlewares = append(middlewares, q.middlewares...)
+
+	return Chain(middlewares...)(f)
+}
+
+// LoggingMiddleware logs the message id, duration and error of each handled message.
+// Failures are logged at LevelWarn and successes at LevelDebug. If logger is nil, nothing is logged.
+func LoggingMiddleware(logger Logger) Middleware {
+	if logger == nil {
+		logger = nopLogger{}
+	}
+
+	return func(next MessageHandler) MessageHandler {
+		return func(ctx context.Context, m types.Message) error {
+			start := time.Now()
+			err := next(ctx, m)
+			if err != nil {
+				action, _ := Classify(err)
+				logger.WarnContext(ctx, "message handling failed", "message_id", aws.ToString(m.MessageId), "duration", time.Since(start), "action", action, "error", err)
+			} else {
+				logger.DebugContext(ctx, "message handled", "message_id", aws.ToString(m.MessageId), "duration", time.Since(start))
+			}
+			return err
+		}
+	}
+}
+
+// TimeoutMiddleware cancels the context passed to the handler after d.
+func TimeoutMiddleware(d time.Duration) Middleware {
+	return func(next MessageHandler) MessageHandler {
+		return func(ctx context.Context, m types.Message) error {
+			ctx, cancel := context.WithTimeout(ctx, d)
+			defer cancel()
+			return next(ctx, m)
+		}
+	}
+}
+
+// RecoverMiddleware converts a panic in the handler into a PanicError wrapped with Retry.
+// Consumers already recover panics outside all middlewares; use this to recover closer to the handler.
+func RecoverMiddleware() Middleware {
+	return func(next MessageHandler) MessageHandler {
+		return func(ctx context.Context, m types.Message) (err error) {
+			defer func() {
+				if r := recover(); r != nil {
+					err = Retry(newPanicError(r))
+				}
+			}()
+			return next(ctx, m)
+		}
+	}
+}
+
+// MetricsMiddleware calls observe with the duration and error of each handled message.
+func MetricsMiddleware(observe func(m types.Message, duration time.Duration, err error)) Middleware {
+	return func(next MessageHandler) MessageHandler {
+		return func(ctx context.Context, m types.Message) error {
+			start := time.Now()
+			err := next(ctx, m)
+			observe(m, time.Since(start), err)
+			return err
+		}
+	}
+}
+
+// TracingMiddleware calls start before each handled message and the returned end function after it.
+// The context returned by start is passed to the handler.
+func TracingMiddleware(start func(ctx context.Context, m types.Message) (context.Context, func(err error))) Middleware {
+	return func(next MessageHandler) MessageHandler {
+		return func(ctx context.Context, m types.Message) error {
+			ctx, end := start(ctx, m)
+			err := next(ctx, m)
+			end(err)
+			return err
+		}
+	}
+}
@@ -0,0 +1,502 @@
+package pubsub
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Attribute names set by the option structs.
+const (
+	QueueAttributeVisibilityTimeout             = "VisibilityTimeout"
+	QueueAttributeMessageRetentionPeriod        = "MessageRetentionPeriod"
+	QueueAttributeDelaySeconds                  = "DelaySeconds"
+	QueueAttributeReceiveMessageWaitTimeSeconds = "ReceiveMessageWaitTimeSeconds"
+	QueueAttributeMaximumMessageSize            = "MaximumMessageSize"
+	QueueAttributeKmsMasterKeyId                = "KmsMasterKeyId"
+	QueueAttributeKmsDataKeyReusePeriodSeconds  = "KmsDataKeyReusePeriodSeconds"
+	QueueAttributeSqsManagedSseEnabled          = "SqsManagedSseEnabled"
+	QueueAttributeFifoQueue                     = "FifoQueue"
+	QueueAttributeContentBasedDeduplication     = "ContentBasedDeduplication"
+	QueueAttributeDeduplicationScope            = "DeduplicationScope"
+	QueueAttributeFifoThroughputLimit           = "FifoThroughputLimit"
+	QueueAttributePolicy                        = "Policy"
+
+	TopicAttributeDisplayName               = "DisplayName"
+	TopicAttributePolicy                    = "Policy"
+	TopicAttributeDeliveryPolicy            = "DeliveryPolicy"
+	TopicAttributeKmsMasterKeyId            = "KmsMasterKeyId"
+	TopicAttributeFifoTopic                 = "FifoTopic"
+	TopicAttributeContentBasedDeduplication = "ContentBasedDeduplication"
+	TopicAttributeSignatureVersion          = "SignatureVersion"
+	TopicAttributeTracingConfig             = "TracingConfig"
+
+	SubscriptionAttributeFilterPolicy        = "FilterPolicy"
+	SubscriptionAttributeFilterPolicyScope   = "FilterPolicyScope"
+	SubscriptionAttributeRawMessageDelivery  = "RawMessageDelivery"
+	SubscriptionAttributeRedrivePolicy       = "RedrivePolicy"
+	SubscriptionAttributeDeliveryPolicy      = "DeliveryPolicy"
+	SubscriptionAttributeSubscriptionRoleArn = "SubscriptionRoleArn"
+)
+
+// fifoSuffix is the name suffix of FIFO queues and topics.
+const fifoSuffix = ".fifo"
+
+// OptionError is returned when an option is out of range or inconsistent with the others.
+type OptionError struct {
+	Option string
+	Reason string
+}
+
+// Error returns the option and the reason.
+func (e *OptionError) Error() string {
+	return fmt.Sprintf("invalid option %s: %s", e.Option, e.Reason)
+}
+
+// checkDuration returns an OptionError if d is not within [min, max] or is not whole seconds,
+// which is the unit of the SQS attributes.
+func checkDuration(option string, d, min, max time.Duration) error {
+	if d < min || d > max {
+		return &OptionError{Option: option, Reason: fmt.Sprintf("%s is not within [%s, %s]", d, min, max)}
+	}
+	if d%time.Second != 0 {
+		return &OptionError{Option: option, Reason: fmt.Sprintf("%s is not whole seconds", d)}
+	}
+
+	return nil
+}
+
+// checkJSON returns an OptionError if s is not a JSON document.
+func checkJSON(option, s string) error {
+	if !json.Valid([]byte(s)) {
+		return &OptionError{Option: option, Reason: "not a JSON document"}
+	}
+
+	return nil
+}
+
+// checkOneOf returns an OptionError if s is not one of the values.
+func checkOneOf(option, s string, values ...string) error {
+	if !containsString(values, s) {
+		return &OptionError{Option: option, Reason: fmt.Sprintf("%q is not one of %s", s, strings.Join(values, ", "))}
+	}
+
+	return nil
+}
+
+// seconds formats a duration as seconds. checkDuration has rejected durations that are not whole seconds.
+func seconds(d time.Duration) string {
+	return strconv.FormatInt(int64(d/time.Second), 10)
+}
+
+// QueueOptions are the attributes of a new queue. Zero fields are left to the AWS defaults, so a zero
+// duration, e.g. a VisibilityTimeout of 0, is set through Attributes. Durations must be whole seconds,
+// and Attributes sets any other attribute as is.
+type QueueOptions struct {
+	// VisibilityTimeout is within [0s, 12h].
+	VisibilityTimeout time.Duration
+	// MessageRetentionPeriod is within [1m, 14d].
+	MessageRetentionPeriod time.Duration
+	// Delay is within [0s, 15m].
+	Delay time.Duration
+	// ReceiveMessageWaitTime is within [0s, 20s].
+	ReceiveMessageWaitTime time.Duration
+	// MaximumMessageSize is within [1024, 262144] bytes.
+	MaximumMessageSize int
+	KmsMasterKeyId     string
+	// KmsDataKeyReusePeriod is within [1m, 24h] and requires KmsMasterKeyId.
+	KmsDataKeyReusePeriod time.Duration
+	// SqsManagedSSE enables or disables SQS-owned encryption keys. Nil leaves the default.
+	SqsManagedSSE *bool
+	// FifoQueue requires a queue name ending with .fifo.
+	FifoQueue bool
+	// ContentBasedDeduplication, DeduplicationScope and FifoThroughputLimit require FifoQueue.
+	ContentBasedDeduplication bool
+	// DeduplicationScope is messageGroup or queue.
+	DeduplicationScope string
+	// FifoThroughputLimit is perQueue or perMessageGroupId.
+	FifoThroughputLimit string
+	// RedrivePolicy moves messages to a dead-letter queue after MaxReceiveCount receives.
+	RedrivePolicy *RedrivePolicy
+	// Policy is the access policy JSON document.
+	Policy     string
+	Attributes map[string]string
+}
+
+// RedrivePolicy is the redrive policy of a queue.
+type RedrivePolicy struct {
+	DeadLetterTargetArn string
+	// MaxReceiveCount is within [1, 1000].
+	MaxReceiveCount int64
+}
+
+// Validate checks the ranges of the options and the FIFO options for a new queue named queueName.
+func (o QueueOptions) Validate(queueName string) error {
+	return o.validate(queueName, true)
+}
+
+// validate checks the options for a new queue, or for an existing one whose FIFO type cannot change.
+func (o QueueOptions) validate(queueName string, create bool) error {
+	if err := checkDuration(QueueAttributeVisibilityTimeout, o.VisibilityTimeout, 0, 12*time.Hour); err != nil {
+		return err
+	}
+	if o.MessageRetentionPeriod != 0 {
+		if err := checkDuration(QueueAttributeMessageRetentionPeriod, o.MessageRetentionPeriod, time.Minute, 14*24*time.Hour); err != nil {
+			return err
+		}
+	}
+	if err := checkDuration(QueueAttributeDelaySeconds, o.Delay, 0, 15*time.Minute); err != nil {
+		return err
+	}
+	if err := checkDuration(QueueAttributeReceiveMessageWaitTimeSeconds, o.ReceiveMessageWaitTime, 0, 20*time.Second); err != nil {
+		return err
+	}
+	if o.MaximumMessageSize != 0 && (o.MaximumMessageSize < 1024 || o.MaximumMessageSize > 262144) {
+		return &OptionError{Option: QueueAttributeMaximumMessageSize, Reason: fmt.Sprintf("%d is not within [1024, 262144]", o.MaximumMessageSize)}
+	}
+	if o.KmsDataKeyReusePeriod != 0 {
+		if o.KmsMasterKeyId == "" {
+			return &OptionError{Option: QueueAttributeKmsDataKeyReusePeriodSeconds, Reason: "requires KmsMasterKeyId"}
+		}
+		if err := checkDuration(QueueAttributeKmsDataKeyReusePeriodSeconds, o.KmsDataKeyReusePeriod, time.Minute, 24*time.Hour); err != nil {
+			return err
+		}
+	}
+	if o.KmsMasterKeyId != "" && o.SqsManagedSSE != nil && *o.SqsManagedSSE {
+		return &OptionError{Option: QueueAttributeSqsManagedSseEnabled, Reason: "cannot be combined with KmsMasterKeyId"}
+	}
+
+	fifo := strings.HasSuffix(queueName, fifoSuffix)
+	if !create && o.FifoQueue {
+		return &OptionError{Option: QueueAttributeFifoQueue, Reason: "cannot be changed"}
+	}
+	if create && (o.FifoQueue || o.Attributes[QueueAttributeFifoQueue] == "true") != fifo {
+		return &OptionError{Option: QueueAttributeFifoQueue, Reason: "must be set exactly when the queue name ends with " + fifoSuffix}
+	}
+	if !fifo {
+		switch {
+		case o.ContentBasedDeduplication:
+			return &OptionError{Option: QueueAttributeContentBasedDeduplication, Reason: "requires FifoQueue"}
+		case o.DeduplicationScope != "":
+			return &OptionError{Option: QueueAttributeDeduplicationScope, Reason: "requires FifoQueue"}
+		case o.FifoThroughputLimit != "":
+			return &OptionError{Option: QueueAttributeFifoThroughputLimit, Reason: "requires FifoQueue"}
+		}
+	}
+	if o.DeduplicationScope != "" {
+		if err := checkOneOf(QueueAttributeDeduplicationScope, o.DeduplicationScope, "messageGroup", "queue"); err != nil {
+			return err
+		}
+	}
+	if o.FifoThroughputLimit != "" {
+		if err := checkOneOf(QueueAttributeFifoThroughputLimit, o.FifoThroughputLimit, "perQueue", "perMessageGroupId"); err != nil {
+			return err
+		}
+	}
+
+	if o.RedrivePolicy != nil {
+		if o.RedrivePolicy.DeadLetterTargetArn == "" {
+			return &OptionError{Option: QueueAttributeRedrivePolicy, Reason: "DeadLetterTargetArn is empty"}
+		}
+		if o.RedrivePolicy.MaxReceiveCount < 1 || o.RedrivePolicy.MaxReceiveCount > 1000 {
+			return &OptionError{Option: QueueAttributeRedrivePolicy, Reason: fmt.Sprintf("MaxReceiveCount %d is not within [1, 1000]", o.RedrivePolicy.MaxReceiveCount)}
+		}
+	}
+	if o.Policy != "" {
+		if err := checkJSON(QueueAttributePolicy, o.Policy); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// attributes returns the options as queue attributes.
+func (o QueueOptions) attributes() map[string]string {
+	attributes := copyAttributes(o.Attributes)
+	if o.VisibilityTimeout != 0 {
+		attributes[QueueAttributeVisibilityTimeout] = seconds(o.VisibilityTimeout)
+	}
+	if o.MessageRetentionPeriod != 0 {
+		attributes[QueueAttributeMessageRetentionPeriod] = seconds(o.MessageRetentionPeriod)
+	}
+	if o.Delay != 0 {
+		attributes[QueueAttributeDelaySeconds] = seconds(o.Delay)
+	}
+	if o.ReceiveMessageWaitTime != 0 {
+		attributes[QueueAttributeReceiveMessageWaitTimeSeconds] = seconds(o.ReceiveMessageWaitTime)
+	}
+	if o.MaximumMessageSize != 0 {
+		attributes[QueueAttributeMaximumMessageSize] = strconv.Itoa(o.MaximumMessageSize)
+	}
+	if o.KmsMasterKeyId != "" {
+		attributes[QueueAttributeKmsMasterKeyId] = o.KmsMasterKeyId
+	}
+	if o.KmsDataKeyReusePeriod != 0 {
+		attributes[QueueAttributeKmsDataKeyReusePeriodSeconds] = seconds(o.KmsDataKeyReusePeriod)
+	}
+	if o.SqsManagedSSE != nil {
+		attributes[QueueAttributeSqsManagedSseEnabled] = strconv.FormatBool(*o.SqsManagedSSE)
+	}
+	if o.FifoQueue {
+		attributes[QueueAttributeFifoQueue] = "true"
+	}
+	if o.ContentBasedDeduplication {
+		attributes[QueueAttributeContentBasedDeduplication] = "true"
+	}
+	if o.DeduplicationScope != "" {
+		attributes[QueueAttributeDeduplicationScope] = o.DeduplicationScope
+	}
+	if o.FifoThroughputLimit != "" {
+		attributes[QueueAttributeFifoThroughputLimit] = o.FifoThroughputLimit
+	}
+	if o.RedrivePolicy != nil {
+		attributes[QueueAttributeRedrivePolicy] = redrivePolicy(o.RedrivePolicy.DeadLetterTargetArn, o.RedrivePolicy.MaxReceiveCount)
+	}
+	if o.Policy != "" {
+		attributes[QueueAttributePolicy] = o.Policy
+	}
+
+	return attributes
+}
+
+// TopicOptions are the attributes of a new topic. Zero fields are left to the AWS defaults,
+// and Attributes sets any other attribute as is.
+type TopicOptions struct {
+	// DisplayName is at most 100 characters.
+	DisplayName string
+	// Policy and DeliveryPolicy are JSON documents.
+	Policy         string
+	DeliveryPolicy string
+	KmsMasterKeyId string
+	// FifoTopic requires a topic name ending with .fifo.
+	FifoTopic bool
+	// ContentBasedDeduplication requires FifoTopic.
+	ContentBasedDeduplication bool
+	// SignatureVersion is 1 or 2.
+	SignatureVersion int
+	// TracingConfig is PassThrough or Active.
+	TracingConfig string
+	Attributes    map[string]string
+}
+
+// Validate checks the options for a new topic named topicName.
+func (o TopicOptions) Validate(topicName string) error {
+	return o.validate(topicName, true)
+}
+
+// validate checks the options for a new topic, or for an existing one whose FIFO type cannot change.
+func (o TopicOptions) validate(topicName string, create bool) error {
+	if len(o.DisplayName) > 100 {
+		return &OptionError{Option: TopicAttributeDisplayName, Reason: "longer than 100 characters"}
+	}
+	if o.Policy != "" {
+		if err := checkJSON(TopicAttributePolicy, o.Policy); err != nil {
+			return err
+		}
+	}
+	if o.DeliveryPolicy != "" {
+		if err := checkJSON(TopicAttributeDeliveryPolicy, o.DeliveryPolicy); err != nil {
+			return err
+		}
+	}
+	fifo := strings.HasSuffix(topicName, fifoSuffix)
+	if !create && o.FifoTopic {
+		return &OptionError{Option: TopicAttributeFifoTopic, Reason: "cannot be changed"}
+	}
+	if create && (o.FifoTopic || o.Attributes[TopicAttributeFifoTopic] == "true") != fifo {
+		return &OptionError{Option: TopicAttributeFifoTopic, Reason: "must be set exactly when the topic name ends with " + fifoSuffix}
+	}
+	if o.ContentBasedDeduplication && !fifo {
+		return &OptionError{Option: TopicAttributeContentBasedDeduplication, Reason: "requires FifoTopic"}
+	}
+	if o.SignatureVersion != 0 && o.SignatureVersion != 1 && o.SignatureVersion != 2 {
+		return &OptionError{Option: TopicAttributeSignatureVersion, Reason: fmt.Sprintf("%d is not 1 or 2", o.SignatureVersion)}
+	}
+	if o.TracingConfig != "" {
+		if err := checkOneOf(TopicAttributeTracingConfig, o.TracingConfig, "PassThrough", "Active"); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// attributes returns the options as topic attributes.
+func (o TopicOptions) attributes() map[string]string {
+	attributes := copyAttributes(o.Attributes)
+	if o.DisplayName != "" {
+		attributes[TopicAttributeDisplayName] = o.DisplayName
+	}
+	if o.Policy != "" {
+		attributes[TopicAttributePolicy] = o.Policy
+	}
+	if o.DeliveryPolicy != "" {
+		attributes[TopicAttributeDeliveryPolicy] = o.DeliveryPolicy
+	}
+	if o.KmsMasterKeyId != "" {
+		attributes[TopicAttributeKmsMasterKeyId] = o.KmsMasterKeyId
+	}
+	if o.FifoTopic {
+		attributes[TopicAttributeFifoTopic] = "true"
+	}
+	if o.ContentBasedDeduplication {
+		attributes[TopicAttributeContentBasedDeduplication] = "true"
+	}
+	if o.SignatureVersion != 0 {
+		attributes[TopicAttributeSignatureVersion] = strconv.Itoa(o.SignatureVersion)
+	}
+	if o.TracingConfig != "" {
+		attributes[TopicAttributeTracingConfig] = o.TracingConfig
+	}
+
+	return attributes
+}
+
+// SubscriptionOptions are the attributes of a new subscription. Zero fields are left to the AWS defaults,
+// and Attributes sets any other attribute as is.
+type SubscriptionOptions struct {
+	// FilterPolicy is a JSON document of at most 256 KiB.
+	FilterPolicy string
+	// FilterPolicyScope is MessageAttributes or MessageBody.
+	FilterPolicyScope string
+	// Filter sets FilterPolicy and FilterPolicyScope from a built policy.
+	Filter *FilterPolicy
+	// RawMessageDelivery delivers the message without the SNS envelope.
+	RawMessageDelivery bool
+	// DeadLetterTargetArn is the arn of the queue receiving messages that SNS fails to deliver.
+	DeadLetterTargetArn string
+	// DeliveryPolicy is a JSON document.
+	DeliveryPolicy      string
+	SubscriptionRoleArn string
+	Attributes          map[string]string
+	// GrantQueueAccess merges a statement allowing the topic to send messages to the queue into the queue policy
+	// before subscribing. Without it, SNS drops the messages unless the queue policy already allows the topic.
+	GrantQueueAccess bool
+}
+
+// maxFilterPolicySize is the maximum size of a filter policy.
+const maxFilterPolicySize = 256 * 1024
+
+// Validate checks the options.
+func (o SubscriptionOptions) Validate() error {
+	if o.FilterPolicy != "" {
+		if len(o.FilterPolicy) > maxFilterPolicySize {
+			return &OptionError{Option: SubscriptionAttributeFilterPolicy, Reason: "larger than 256 KiB"}
+		}
+		if err := checkJSON(SubscriptionAttributeFilterPolicy, o.FilterPolicy); err != nil {
+			return err
+		}
+	}
+	if o.Filter != nil {
+		if o.FilterPolicy != "" {
+			return &OptionError{Option: SubscriptionAttributeFilterPolicy, Reason: "cannot be combined with Filter"}
+		}
+		if o.FilterPolicyScope != "" && o.FilterPolicyScope != o.Filter.Scope() {
+			return &OptionError{Option: SubscriptionAttributeFilterPolicyScope, Reason: "differs from the scope of Filter"}
+		}
+		policy, err := o.Filter.JSON()
+		if err != nil {
+			return &OptionError{Option: SubscriptionAttributeFilterPolicy, Reason: err.Error()}
+		}
+		if len(policy) > maxFilterPolicySize {
+			return &OptionError{Option: SubscriptionAttributeFilterPolicy, Reason: "larger than 256 KiB"}
+		}
+	}
+	if o.FilterPolicyScope != "" {
+		if err := checkOneOf(SubscriptionAttributeFilterPolicyScope, o.FilterPolicyScope, FilterPolicyScopeMessageAttributes, FilterPolicyScopeMessageBody); err != nil {
+			return err
+		}
+	}
+	if o.DeliveryPolicy != "" {
+		if err := checkJSON(SubscriptionAttributeDeliveryPolicy, o.DeliveryPolicy); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// attributes returns the options as subscription attributes.
+func (o SubscriptionOptions) attributes() map[string]string {
+	attributes := copyAttributes(o.Attributes)
+	if o.FilterPolicy != "" {
+		attributes[SubscriptionAttributeFilterPolicy] = o.FilterPolicy
+	}
+	if o.FilterPolicyScope != "" {
+		attributes[SubscriptionAttributeFilterPolicyScope] = o.FilterPolicyScope
+	}
+	if o.Filter != nil {
+		// Validate has checked the policy.
+		attributes[SubscriptionAttributeFilterPolicy], _ = o.Filter.JSON()
+		attributes[SubscriptionAttributeFilterPolicyScope] = o.Filter.Scope()
+	}
+	if o.RawMessageDelivery {
+		attributes[SubscriptionAttributeRawMessageDelivery] = "true"
+	}
+	if o.DeadLetterTargetArn != "" {
+		b, _ := json.Marshal(struct {
+			DeadLetterTargetArn string `json:"deadLetterTargetArn"`
+		}{o.DeadLetterTargetArn})
+		attributes[SubscriptionAttributeRedrivePolicy] = string(b)
+	}
+	if o.DeliveryPolicy != "" {
+		attributes[SubscriptionAttributeDeliveryPolicy] = o.DeliveryPolicy
+	}
+	if o.SubscriptionRoleArn != "" {
+		attributes[SubscriptionAttributeSubscriptionRoleArn] = o.SubscriptionRoleArn
+	}
+
+	return attributes
+}
+
+// CreateQueueWithOptions calls the CreateQueueWithOptionsContext method.
+func (c *PubsubClient) CreateQueueWithOptions(queueName string, opts QueueOptions) (*Queue, error) {
+	return c.CreateQueueWithOptionsContext(context.Background(), queueName, opts)
+}
+
+// CreateQueueWithOptionsContext validates the options and returns an initialized queue client based on the queue name.
+func (c *PubsubClient) CreateQueueWithOptionsContext(ctx context.Context, queueName string, opts QueueOptions) (*Queue, error) {
+	if err := opts.Validate(queueName); err != nil {
+		return nil, fmt.Errorf("opts.Validate: %w", err)
+	}
+
+	return c.CreateQueueContext(ctx, queueName, toOpts(opts.attributes()))
+}
+
+// CreateTopicWithOptions calls the CreateTopicWithOptionsContext method.
+func (c *PubsubClient) CreateTopicWithOptions(topicName string, opts TopicOptions) (*Topic, error) {
+	return c.CreateTopicWithOptionsContext(context.Background(), topicName, opts)
+}
+
+// CreateTopicWithOptionsContext validates the options and returns an initialized topic client based on the topic name.
+func (c *PubsubClient) CreateTopicWithOptionsContext(ctx context.Context, topicName string, opts TopicOptions) (*Topic, error) {
+	if err := opts.Validate(topicName); err != nil {
+		return nil, fmt.Errorf("opts.Validate: %w", err)
+	}
+
+	return c.CreateTopicContext(ctx, topicName, toOpts(opts.attributes()))
+}
+
+// CreateSubscriptionWithOptions calls the CreateSubscriptionWithOptionsContext method.
+func (c *PubsubClient) CreateSubscriptionWithOptions(topic *Topic, queue *Queue, opts SubscriptionOptions) (*Subscription, error) {
+	return c.CreateSubscriptionWithOptionsContext(context.Background(), topic, queue, opts)
+}
+
+// CreateSubscriptionWithOptionsContext validates the options and returns an initialized subscription client
+// based on the topic and queue.
+func (c *PubsubClient) CreateSubscriptionWithOptionsContext(ctx context.Context, topic *Topic, queue *Queue, opts SubscriptionOptions) (*Subscription, error) {
+	if err := opts.Validate(); err != nil {
+		return nil, fmt.Errorf("opts.Validate: %w", err)
+	}
+	if opts.GrantQueueAccess {
+		if err := queue.AllowTopic(ctx, topic); err != nil {
+			return nil, fmt.Errorf("queue.AllowTopic: %w", err)
+		}
+	}
+
+	return c.CreateSubscriptionContext(ctx, topic, queue, toOpts(opts.attributes()))
+}
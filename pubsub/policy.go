@@ -0,0 +1,423 @@
+package pubsub
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/aws/arn"
+	"github.com/aws/aws-sdk-go-v2/service/sns"
+	"github.com/aws/aws-sdk-go-v2/service/sqs"
+	"github.com/aws/aws-sdk-go-v2/service/sqs/types"
+)
+
+// PolicyVersion is the current version of the IAM policy language.
+const PolicyVersion = "2012-10-17"
+
+const (
+	PolicyEffectAllow = "Allow"
+	PolicyEffectDeny  = "Deny"
+)
+
+// PolicyDocument is an IAM resource policy, such as the Policy attribute of a queue or a topic.
+type PolicyDocument struct {
+	Version   string           `json:"Version"`
+	Id        string           `json:"Id,omitempty"`
+	Statement PolicyStatements `json:"Statement"`
+}
+
+// PolicyStatements are the statements of a policy document, which IAM also accepts as a single statement object.
+type PolicyStatements []PolicyStatement
+
+// UnmarshalJSON accepts both a single statement and an array.
+func (l *PolicyStatements) UnmarshalJSON(b []byte) error {
+	if b = bytes.TrimSpace(b); len(b) > 0 && b[0] == '{' {
+		var s PolicyStatement
+		if err := json.Unmarshal(b, &s); err != nil {
+			return fmt.Errorf("json.Unmarshal: %w", err)
+		}
+		*l = PolicyStatements{s}
+		return nil
+	}
+
+	var ss []PolicyStatement
+	if err := json.Unmarshal(b, &ss); err != nil {
+		return fmt.Errorf("json.Unmarshal: %w", err)
+	}
+	*l = ss
+
+	return nil
+}
+
+// PolicyStatement is a statement of a policy document. The Not fields are kept so that merging a statement
+// into an existing policy preserves the other statements.
+type PolicyStatement struct {
+	Sid          string     `json:"Sid,omitempty"`
+	Effect       string     `json:"Effect"`
+	Principal    Principal  `json:"Principal,omitempty"`
+	NotPrincipal Principal  `json:"NotPrincipal,omitempty"`
+	Action       StringList `json:"Action,omitempty"`
+	NotAction    StringList `json:"NotAction,omitempty"`
+	Resource     StringList `json:"Resource,omitempty"`
+	NotResource  StringList `json:"NotResource,omitempty"`
+	// Condition maps operators, e.g. ArnEquals, to condition keys and their values. The values are kept as decoded,
+	// e.g. a string, a bool or a list, so that merging a statement does not change the other conditions.
+	Condition map[string]map[string]interface{} `json:"Condition,omitempty"`
+}
+
+// Principal maps principal types, e.g. AWS or Service, to principals. The "*" principal is {"AWS": ["*"]}.
+type Principal map[string]StringList
+
+// UnmarshalJSON accepts both "*" and a principal object.
+func (p *Principal) UnmarshalJSON(b []byte) error {
+	var s string
+	if err := json.Unmarshal(b, &s); err == nil {
+		*p = Principal{"AWS": {s}}
+		return nil
+	}
+
+	var m map[string]StringList
+	if err := json.Unmarshal(b, &m); err != nil {
+		return fmt.Errorf("json.Unmarshal: %w", err)
+	}
+	*p = m
+
+	return nil
+}
+
+// StringList is a list of strings written as a single string when it has one element, as in IAM policies.
+type StringList []string
+
+// MarshalJSON writes a single string or an array.
+func (l StringList) MarshalJSON() ([]byte, error) {
+	if len(l) == 1 {
+		return json.Marshal(l[0])
+	}
+
+	return json.Marshal([]string(l))
+}
+
+// UnmarshalJSON accepts both a single string and an array.
+func (l *StringList) UnmarshalJSON(b []byte) error {
+	var s string
+	if err := json.Unmarshal(b, &s); err == nil {
+		*l = StringList{s}
+		return nil
+	}
+
+	var ss []string
+	if err := json.Unmarshal(b, &ss); err != nil {
+		return fmt.Errorf("json.Unmarshal: %w", err)
+	}
+	*l = ss
+
+	return nil
+}
+
+// NewPolicyDocument returns a policy document with the statements.
+func NewPolicyDocument(statements ...PolicyStatement) *PolicyDocument {
+	return &PolicyDocument{Version: PolicyVersion, Statement: statements}
+}
+
+// ParsePolicyDocument parses a policy JSON document. An empty string is an empty policy.
+func ParsePolicyDocument(policy string) (*PolicyDocument, error) {
+	if policy == "" {
+		return NewPolicyDocument(), nil
+	}
+
+	var d PolicyDocument
+	if err := json.Unmarshal([]byte(policy), &d); err != nil {
+		return nil, fmt.Errorf("json.Unmarshal: %w", err)
+	}
+
+	return &d, nil
+}
+
+// Add adds the statement, replacing the statement whose Sid is equal to the Sid of s if any.
+// A statement without Sid is always appended.
+func (d *PolicyDocument) Add(s PolicyStatement) *PolicyDocument {
+	if s.Sid != "" {
+		for i := range d.Statement {
+			if d.Statement[i].Sid == s.Sid {
+				d.Statement[i] = s
+				return d
+			}
+		}
+	}
+	d.Statement = append(d.Statement, s)
+
+	return d
+}
+
+// Contains returns whether the document has a statement equivalent to s, ignoring the Sid, the case of actions
+// and condition keys, the order of lists and single-value lists. A statement granting to every principal, as
+// written by the console, also contains a statement granting to a specific principal.
+func (d *PolicyDocument) Contains(s PolicyStatement) bool {
+	s = normalizeStatement(s)
+	for _, existing := range d.Statement {
+		existing = normalizeStatement(existing)
+		if existing.Principal.isWildcard() {
+			existing.Principal = s.Principal
+		}
+		if reflect.DeepEqual(existing, s) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// isWildcard returns whether the principal is every principal.
+func (p Principal) isWildcard() bool {
+	return len(p) == 1 && len(p["AWS"]) == 1 && p["AWS"][0] == "*"
+}
+
+// normalizeStatement returns a copy of the statement without Sid, with lowercase actions and condition keys,
+// sorted lists and single-value condition lists as values.
+func normalizeStatement(s PolicyStatement) PolicyStatement {
+	n := PolicyStatement{
+		Effect:       s.Effect,
+		Principal:    normalizePrincipal(s.Principal),
+		NotPrincipal: normalizePrincipal(s.NotPrincipal),
+		Action:       normalizeStringList(s.Action, true),
+		NotAction:    normalizeStringList(s.NotAction, true),
+		Resource:     normalizeStringList(s.Resource, false),
+		NotResource:  normalizeStringList(s.NotResource, false),
+	}
+	if len(s.Condition) > 0 {
+		n.Condition = make(map[string]map[string]interface{}, len(s.Condition))
+		for operator, keys := range s.Condition {
+			values := make(map[string]interface{}, len(keys))
+			for key, value := range keys {
+				values[strings.ToLower(key)] = normalizeConditionValue(value)
+			}
+			n.Condition[operator] = values
+		}
+	}
+
+	return n
+}
+
+// normalizePrincipal returns a copy of the principal with sorted lists.
+func normalizePrincipal(p Principal) Principal {
+	if len(p) == 0 {
+		return nil
+	}
+
+	n := make(Principal, len(p))
+	for k, v := range p {
+		n[k] = normalizeStringList(v, false)
+	}
+
+	return n
+}
+
+// normalizeStringList returns a sorted copy of the list, lowercased if lower is set, or nil if it is empty.
+func normalizeStringList(l StringList, lower bool) StringList {
+	if len(l) == 0 {
+		return nil
+	}
+
+	n := make(StringList, 0, len(l))
+	for _, s := range l {
+		if lower {
+			s = strings.ToLower(s)
+		}
+		n = append(n, s)
+	}
+	sort.Strings(n)
+
+	return n
+}
+
+// normalizeConditionValue returns a single-value list as its value, and a list of strings sorted.
+func normalizeConditionValue(v interface{}) interface{} {
+	list, ok := v.([]interface{})
+	if !ok {
+		return v
+	}
+	if len(list) == 1 {
+		return list[0]
+	}
+
+	strs := make([]string, 0, len(list))
+	for _, e := range list {
+		s, ok := e.(string)
+		if !ok {
+			return v
+		}
+		strs = append(strs, s)
+	}
+	sort.Strings(strs)
+
+	return conditionValue(strs)
+}
+
+// JSON returns the document as the value of a Policy attribute.
+func (d *PolicyDocument) JSON() (string, error) {
+	b, err := json.Marshal(d)
+	if err != nil {
+		return "", fmt.Errorf("json.Marshal: %w", err)
+	}
+
+	return string(b), nil
+}
+
+// AllowTopicToSendToQueue returns the queue policy statement that lets SNS deliver the messages of the topic to the queue.
+func AllowTopicToSendToQueue(queueArn, topicArn string) PolicyStatement {
+	return PolicyStatement{
+		Sid:       "AllowSNS" + policySid(topicArn),
+		Effect:    PolicyEffectAllow,
+		Principal: Principal{"Service": {"sns.amazonaws.com"}},
+		Action:    StringList{"sqs:SendMessage"},
+		Resource:  StringList{queueArn},
+		Condition: map[string]map[string]interface{}{"ArnEquals": {"aws:SourceArn": topicArn}},
+	}
+}
+
+// AllowServiceToPublish returns the topic policy statement that lets an AWS service, e.g. s3.amazonaws.com or
+// events.amazonaws.com, publish to the topic on behalf of the source resources, which may contain wildcards.
+func AllowServiceToPublish(topicArn, service string, sourceArns ...string) PolicyStatement {
+	s := PolicyStatement{
+		Sid:       "Allow" + policySid(append([]string{service}, sourceArns...)...),
+		Effect:    PolicyEffectAllow,
+		Principal: Principal{"Service": {service}},
+		Action:    StringList{"sns:Publish"},
+		Resource:  StringList{topicArn},
+	}
+	if len(sourceArns) > 0 {
+		s.Condition = map[string]map[string]interface{}{"ArnLike": {"aws:SourceArn": conditionValue(sourceArns)}}
+	}
+
+	return s
+}
+
+// policySid returns a Sid suffix made of the alphanumeric characters of the resource name of the first arn, or of
+// the first string if it is not an arn, and of a short hash of all the strings. The hash tells apart arns with
+// the same name in other regions or accounts, and names that differ only by other characters, e.g. orders.fifo.
+func policySid(ss ...string) string {
+	name := ss[0]
+	if parsed, err := arn.Parse(name); err == nil {
+		name = parsed.Resource
+	}
+	name = strings.Map(func(r rune) rune {
+		if r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r >= '0' && r <= '9' {
+			return r
+		}
+		return -1
+	}, name)
+
+	sum := sha256.Sum256([]byte(strings.Join(ss, "\n")))
+	return name + hex.EncodeToString(sum[:4])
+}
+
+// conditionValue returns the condition value of the strings as decoded from JSON: a string if there is one,
+// and a list otherwise.
+func conditionValue(ss []string) interface{} {
+	if len(ss) == 1 {
+		return ss[0]
+	}
+
+	values := make([]interface{}, 0, len(ss))
+	for _, s := range ss {
+		values = append(values, s)
+	}
+	return values
+}
+
+// Policy returns the access policy of the queue, empty if it has none.
+func (q *Queue) Policy(ctx context.Context) (*PolicyDocument, error) {
+	output, err := q.client.SQS.GetQueueAttributes(ctx, &sqs.GetQueueAttributesInput{
+		AttributeNames: []types.QueueAttributeName{types.QueueAttributeNamePolicy},
+		QueueUrl:       aws.String(q.queueUrl),
+	})
+	if err != nil {
+		return nil, fmt.Errorf("q.client.SQS.GetQueueAttributes: %w", err)
+	}
+
+	d, err := ParsePolicyDocument(output.Attributes[QueueAttributePolicy])
+	if err != nil {
+		return nil, fmt.Errorf("ParsePolicyDocument: %w", err)
+	}
+
+	return d, nil
+}
+
+// SetPolicy replaces the access policy of the queue.
+func (q *Queue) SetPolicy(ctx context.Context, d *PolicyDocument) error {
+	policy, err := d.JSON()
+	if err != nil {
+		return fmt.Errorf("d.JSON: %w", err)
+	}
+
+	if _, err := q.client.SQS.SetQueueAttributes(ctx, &sqs.SetQueueAttributesInput{
+		QueueUrl:   aws.String(q.queueUrl),
+		Attributes: map[string]string{QueueAttributePolicy: policy},
+	}); err != nil {
+		return fmt.Errorf("q.client.SQS.SetQueueAttributes: %w", err)
+	}
+
+	return nil
+}
+
+// AllowTopic merges the statement of AllowTopicToSendToQueue into the access policy of the queue, keeping
+// the other statements. The policy is left untouched if it already has the statement.
+// Concurrent changes of the policy between the read and the write are lost.
+func (q *Queue) AllowTopic(ctx context.Context, topic *Topic) error {
+	d, err := q.Policy(ctx)
+	if err != nil {
+		return fmt.Errorf("q.Policy: %w", err)
+	}
+
+	s := AllowTopicToSendToQueue(q.queueArn, topic.topicArn)
+	if d.Contains(s) {
+		return nil
+	}
+	if err := q.SetPolicy(ctx, d.Add(s)); err != nil {
+		return fmt.Errorf("q.SetPolicy: %w", err)
+	}
+
+	return nil
+}
+
+// Policy returns the access policy of the topic.
+func (t *Topic) Policy(ctx context.Context) (*PolicyDocument, error) {
+	output, err := t.client.SNS.GetTopicAttributes(ctx, &sns.GetTopicAttributesInput{
+		TopicArn: &t.topicArn,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("t.client.SNS.GetTopicAttributes: %w", err)
+	}
+
+	d, err := ParsePolicyDocument(output.Attributes[TopicAttributePolicy])
+	if err != nil {
+		return nil, fmt.Errorf("ParsePolicyDocument: %w", err)
+	}
+
+	return d, nil
+}
+
+// SetPolicy replaces the access policy of the topic.
+func (t *Topic) SetPolicy(ctx context.Context, d *PolicyDocument) error {
+	policy, err := d.JSON()
+	if err != nil {
+		return fmt.Errorf("d.JSON: %w", err)
+	}
+
+	if _, err := t.client.SNS.SetTopicAttributes(ctx, &sns.SetTopicAttributesInput{
+		TopicArn:       &t.topicArn,
+		AttributeName:  aws.String(TopicAttributePolicy),
+		AttributeValue: aws.String(policy),
+	}); err != nil {
+		return fmt.Errorf("t.client.SNS.SetTopicAttributes: %w", err)
+	}
+
+	return nil
+}
@@ -0,0 +1,160 @@
+package pubsub
+
+import (
+	"testing"
+)
+
+func TestParsePolicyDocument(t *testing.T) {
+	tests := []struct {
+		name   string
+		policy string
+		want   string
+	}{
+		{
+			name:   "empty",
+			policy: "",
+			want:   `{"Version":"2012-10-17","Statement":null}`,
+		},
+		{
+			name:   "single statement",
+			policy: `{"Version":"2012-10-17","Statement":{"Effect":"Allow","Principal":{"Service":"sns.amazonaws.com"},"Action":"sqs:SendMessage","Resource":"arn:aws:sqs:eu-west-1:123456789012:orders"}}`,
+			want:   `{"Version":"2012-10-17","Statement":[{"Effect":"Allow","Principal":{"Service":"sns.amazonaws.com"},"Action":"sqs:SendMessage","Resource":"arn:aws:sqs:eu-west-1:123456789012:orders"}]}`,
+		},
+		{
+			name:   "wildcard principal",
+			policy: `{"Version":"2012-10-17","Statement":[{"Effect":"Allow","Principal":"*","Action":["sqs:SendMessage","sqs:ReceiveMessage"],"Resource":"*"}]}`,
+			want:   `{"Version":"2012-10-17","Statement":[{"Effect":"Allow","Principal":{"AWS":"*"},"Action":["sqs:SendMessage","sqs:ReceiveMessage"],"Resource":"*"}]}`,
+		},
+		{
+			name:   "non-string conditions",
+			policy: `{"Version":"2012-10-17","Id":"policy","Statement":[{"Sid":"DenyInsecure","Effect":"Deny","Principal":"*","Action":"sqs:*","Resource":"*","Condition":{"Bool":{"aws:SecureTransport":false},"NumericLessThan":{"s3:max-keys":10},"ArnLike":{"aws:SourceArn":["arn:aws:sns:*:123456789012:a","arn:aws:sns:*:123456789012:b"]}}}]}`,
+			want:   `{"Version":"2012-10-17","Id":"policy","Statement":[{"Sid":"DenyInsecure","Effect":"Deny","Principal":{"AWS":"*"},"Action":"sqs:*","Resource":"*","Condition":{"ArnLike":{"aws:SourceArn":["arn:aws:sns:*:123456789012:a","arn:aws:sns:*:123456789012:b"]},"Bool":{"aws:SecureTransport":false},"NumericLessThan":{"s3:max-keys":10}}}]}`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			d, err := ParsePolicyDocument(tt.policy)
+			if err != nil {
+				t.Fatalf("ParsePolicyDocument: %v", err)
+			}
+			got, err := d.JSON()
+			if err != nil {
+				t.Fatalf("JSON: %v", err)
+			}
+			if got != tt.want {
+				t.Errorf("JSON = %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestPolicyDocumentContains(t *testing.T) {
+	const (
+		queueArn = "arn:aws:sqs:eu-west-1:123456789012:orders"
+		topicArn = "arn:aws:sns:eu-west-1:123456789012:orders"
+	)
+	statement := AllowTopicToSendToQueue(queueArn, topicArn)
+
+	tests := []struct {
+		name   string
+		policy string
+		want   bool
+	}{
+		{
+			name:   "empty",
+			policy: "",
+			want:   false,
+		},
+		{
+			name:   "same statement",
+			policy: `{"Version":"2012-10-17","Statement":[{"Sid":"Other","Effect":"Allow","Principal":{"Service":"sns.amazonaws.com"},"Action":"sqs:SendMessage","Resource":"` + queueArn + `","Condition":{"ArnEquals":{"aws:SourceArn":"` + topicArn + `"}}}]}`,
+			want:   true,
+		},
+		{
+			name:   "action case and lists",
+			policy: `{"Version":"2012-10-17","Statement":{"Effect":"Allow","Principal":{"Service":["sns.amazonaws.com"]},"Action":["SQS:SendMessage"],"Resource":["` + queueArn + `"],"Condition":{"ArnEquals":{"aws:SourceArn":["` + topicArn + `"]}}}}`,
+			want:   true,
+		},
+		{
+			name:   "wildcard principal",
+			policy: `{"Version":"2012-10-17","Statement":[{"Effect":"Allow","Principal":{"AWS":"*"},"Action":"SQS:SendMessage","Resource":"` + queueArn + `","Condition":{"ArnEquals":{"aws:SourceArn":"` + topicArn + `"}}}]}`,
+			want:   true,
+		},
+		{
+			name:   "wildcard principal without condition",
+			policy: `{"Version":"2012-10-17","Statement":[{"Effect":"Allow","Principal":"*","Action":"sqs:SendMessage","Resource":"` + queueArn + `"}]}`,
+			want:   false,
+		},
+		{
+			name:   "other topic",
+			policy: `{"Version":"2012-10-17","Statement":[{"Effect":"Allow","Principal":{"Service":"sns.amazonaws.com"},"Action":"sqs:SendMessage","Resource":"` + queueArn + `","Condition":{"ArnEquals":{"aws:SourceArn":"` + topicArn + `-dlq"}}}]}`,
+			want:   false,
+		},
+		{
+			name:   "deny",
+			policy: `{"Version":"2012-10-17","Statement":[{"Effect":"Deny","Principal":{"Service":"sns.amazonaws.com"},"Action":"sqs:SendMessage","Resource":"` + queueArn + `","Condition":{"ArnEquals":{"aws:SourceArn":"` + topicArn + `"}}}]}`,
+			want:   false,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			d, err := ParsePolicyDocument(tt.policy)
+			if err != nil {
+				t.Fatalf("ParsePolicyDocument: %v", err)
+			}
+			if got := d.Contains(statement); got != tt.want {
+				t.Errorf("Contains = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestPolicySid(t *testing.T) {
+	tests := []struct {
+		name string
+		a, b []string
+		same bool
+	}{
+		{
+			name: "same arn",
+			a:    []string{"arn:aws:sns:eu-west-1:123456789012:orders"},
+			b:    []string{"arn:aws:sns:eu-west-1:123456789012:orders"},
+			same: true,
+		},
+		{
+			name: "other region",
+			a:    []string{"arn:aws:sns:eu-west-1:123456789012:orders"},
+			b:    []string{"arn:aws:sns:us-east-1:123456789012:orders"},
+		},
+		{
+			name: "other account",
+			a:    []string{"arn:aws:sns:eu-west-1:123456789012:orders"},
+			b:    []string{"arn:aws:sns:eu-west-1:210987654321:orders"},
+		},
+		{
+			name: "non-alphanumeric characters",
+			a:    []string{"arn:aws:sns:eu-west-1:123456789012:orders.fifo"},
+			b:    []string{"arn:aws:sns:eu-west-1:123456789012:ordersfifo"},
+		},
+		{
+			name: "other source",
+			a:    []string{"s3.amazonaws.com", "arn:aws:s3:::a"},
+			b:    []string{"s3.amazonaws.com", "arn:aws:s3:::b"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			a, b := policySid(tt.a...), policySid(tt.b...)
+			if (a == b) != tt.same {
+				t.Errorf("policySid = %s and %s, want same %v", a, b, tt.same)
+			}
+		})
+	}
+
+	if got, want := policySid("arn:aws:sns:eu-west-1:123456789012:orders.fifo")[:len("ordersfifo")], "ordersfifo"; got != want {
+		t.Errorf("policySid prefix = %s, want %s", got, want)
+	}
+}
@@ -3,8 +3,8 @@ package pubsub
 import (
 	"context"
 	"encoding/json"
-	"errors"
 	"fmt"
+	"strconv"
 	"time"
 
 	"github.com/aws/aws-sdk-go-v2/aws"
@@ -41,6 +41,22 @@ type PubsubClient struct {
 	SNS        *sns.Client
 	Config     sqsConfig
 	OpsTimeout time.Duration
+	// Middlewares are applied to every consume variant of every queue, outside the middlewares of the queue.
+	Middlewares []Middleware
+	// PanicRetryable decides whether a message whose handler panicked is retried or dead-lettered, which deletes
+	// it if the queue has neither a forwarding dead-letter queue nor a redrive policy. If nil, the message is retried.
+	PanicRetryable func(m types.Message, err *PanicError) bool
+	// ErrorHook is called with every handler error, including recovered panics.
+	ErrorHook func(ctx context.Context, m types.Message, err error)
+	// ResultHook is called with the outcome of every poll of a queue, e.g. for auditing.
+	ResultHook func(ctx context.Context, result *ConsumeResult)
+	// Logger receives structured logs. If nil, nothing is logged.
+	Logger Logger
+	// RedactBody returns the form of a message body that is logged.
+	// If nil, bodies are replaced by their length.
+	RedactBody func(body string) string
+	// Metrics receives the events of consumers and producers. If nil, nothing is recorded.
+	Metrics Metrics
 }
 
 // NewPubsubClient returns a new client from the provided clients and config.
@@ -127,38 +143,25 @@ func (c *PubsubClient) NewSubscriptionContext(ctx context.Context, subscriptionA
 	if err != nil {
 		return nil, fmt.Errorf("c.SNS.GetSubscriptionAttributes(%s) : %w", subscriptionArn, err)
 	}
-	topic, err := c.NewTopic(atr.Attributes[NameTopicArn])
-	if err != nil {
-		return nil, fmt.Errorf("c.NewTopic: %w", err)
+	if protocol := atr.Attributes["Protocol"]; protocol != *SubscriptionProtocolSQS {
+		return nil, fmt.Errorf("subscription %s has the %s protocol, not sqs", subscriptionArn, protocol)
 	}
-
-	subscriptions, err := c.SNS.ListSubscriptionsByTopic(
-		ctx,
-		&sns.ListSubscriptionsByTopicInput{
-			TopicArn: &topic.topicArn,
-		},
-	)
+	topic, err := c.NewTopicContext(ctx, atr.Attributes[NameTopicArn])
 	if err != nil {
-		return nil, fmt.Errorf("c.SNS.ListSubscriptionsByTopic(%s) : %w", topic.topicArn, err)
+		return nil, fmt.Errorf("c.NewTopicContext: %w", err)
 	}
-
-	for _, subscription := range subscriptions.Subscriptions {
-		if *subscription.Protocol == *SubscriptionProtocolSQS && *subscription.SubscriptionArn == subscriptionArn {
-			queue, err := c.NewQueue(*subscription.Endpoint)
-			if err != nil {
-				return nil, fmt.Errorf("c.NewQueue(%v) : %w", subscription.Endpoint, err)
-			}
-
-			return &Subscription{
-				client:          c,
-				subscriptionArn: subscriptionArn,
-				topic:           *topic,
-				queue:           *queue,
-			}, nil
-		}
+	endpoint := atr.Attributes["Endpoint"]
+	queue, err := c.NewQueueContext(ctx, endpoint)
+	if err != nil {
+		return nil, fmt.Errorf("c.NewQueueContext(%s) : %w", endpoint, err)
 	}
 
-	return nil, errors.New("subscription not found")
+	return &Subscription{
+		client:          c,
+		subscriptionArn: subscriptionArn,
+		topic:           *topic,
+		queue:           *queue,
+	}, nil
 }
 
 // Change old opts format to new format
@@ -181,6 +184,7 @@ func (c *PubsubClient) CreateQueue(queueName string, opts map[string]*string) (*
 }
 
 // CreateQueueContext returns an initialized queue client based on the queue name and options.
+// The options are passed to AWS as is; CreateQueueWithOptionsContext validates them first.
 func (c *PubsubClient) CreateQueueContext(ctx context.Context, queueName string, opts map[string]*string) (*Queue, error) {
 	queue, err := c.SQS.CreateQueue(
 		ctx,
@@ -212,18 +216,70 @@ func (c *PubsubClient) CreateQueueContext(ctx context.Context, queueName string,
 	}, nil
 }
 
+// CreateQueueWithDLQ calls the CreateQueueWithDLQContext method.
 func (c *PubsubClient) CreateQueueWithDLQ(queueName string, dlq Queue, maxReceive int64, opts map[string]*string) (*Queue, error) {
+	return c.CreateQueueWithDLQContext(context.Background(), queueName, dlq, maxReceive, opts)
+}
+
+// CreateQueueWithDLQContext returns an initialized queue client based on the queue name and options,
+// with a redrive policy that moves messages to the dlq after maxReceive receives.
+func (c *PubsubClient) CreateQueueWithDLQContext(ctx context.Context, queueName string, dlq Queue, maxReceive int64, opts map[string]*string) (*Queue, error) {
+	withPolicy := make(map[string]*string, len(opts)+1)
+	for k, v := range opts {
+		withPolicy[k] = v
+	}
+	withPolicy[QueueAttributeRedrivePolicy] = aws.String(redrivePolicy(dlq.queueArn, maxReceive))
+
+	return c.CreateQueueContext(ctx, queueName, withPolicy)
+}
+
+// redrivePolicy returns the RedrivePolicy attribute value for the dead-letter queue arn and max receive count.
+func redrivePolicy(deadLetterTargetArn string, maxReceive int64) string {
 	policy := struct {
 		MaxReceiveCount     int64  `json:"maxReceiveCount"`
 		DeadLetterTargetArn string `json:"deadLetterTargetArn"`
 	}{}
 
-	policy.DeadLetterTargetArn = dlq.queueArn
+	policy.DeadLetterTargetArn = deadLetterTargetArn
 	policy.MaxReceiveCount = maxReceive
-	redrivePolicy, _ := json.Marshal(policy)
+	b, _ := json.Marshal(policy)
+
+	return string(b)
+}
+
+// parseRedrivePolicy parses the RedrivePolicy attribute of a queue or a subscription, returning nil if it is empty.
+// The maxReceiveCount of a queue may be a number or a string, and a subscription has none.
+func parseRedrivePolicy(policy string) (*RedrivePolicy, error) {
+	if policy == "" {
+		return nil, nil
+	}
+
+	var parsed struct {
+		DeadLetterTargetArn string          `json:"deadLetterTargetArn"`
+		MaxReceiveCount     json.RawMessage `json:"maxReceiveCount"`
+	}
+	if err := json.Unmarshal([]byte(policy), &parsed); err != nil {
+		return nil, fmt.Errorf("json.Unmarshal(%s) : %w", QueueAttributeRedrivePolicy, err)
+	}
+	p := &RedrivePolicy{DeadLetterTargetArn: parsed.DeadLetterTargetArn}
+	if len(parsed.MaxReceiveCount) > 0 {
+		maxReceiveCount, err := strconv.ParseInt(string(trimQuotes(parsed.MaxReceiveCount)), 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("strconv.ParseInt(maxReceiveCount) : %w", err)
+		}
+		p.MaxReceiveCount = maxReceiveCount
+	}
+
+	return p, nil
+}
+
+// trimQuotes returns the JSON value without the quotes of a string.
+func trimQuotes(b []byte) []byte {
+	if len(b) >= 2 && b[0] == '"' && b[len(b)-1] == '"' {
+		return b[1 : len(b)-1]
+	}
 
-	opts[QueueAttributeRedrivePolicy] = aws.String(string(redrivePolicy))
-	return c.CreateQueue(queueName, opts)
+	return b
 }
 
 // CreateTopic calls the CreateTopicContext method.
@@ -232,6 +288,7 @@ func (c *PubsubClient) CreateTopic(topicName string, opts map[string]*string) (*
 }
 
 // CreateTopicContext returns an initialized topic client based on the topic name and options.
+// The options are passed to AWS as is; CreateTopicWithOptionsContext validates them first.
 func (c *PubsubClient) CreateTopicContext(ctx context.Context, topicName string, opts map[string]*string) (*Topic, error) {
 	topic, err := c.SNS.CreateTopic(
 		ctx,
@@ -252,6 +309,7 @@ func (c *PubsubClient) CreateSubscription(topic *Topic, queue *Queue, opts map[s
 }
 
 // CreateSubscriptionContext returns an initialized subscription client based on the topic, queue and options.
+// The options are passed to AWS as is; CreateSubscriptionWithOptionsContext validates them first.
 func (c *PubsubClient) CreateSubscriptionContext(ctx context.Context, topic *Topic, queue *Queue, opts map[string]*string) (*Subscription, error) {
 	subscription, err := c.SNS.Subscribe(
 		ctx,
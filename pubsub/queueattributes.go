@@ -0,0 +1,160 @@
+package pubsub
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/sqs"
+	"github.com/aws/aws-sdk-go-v2/service/sqs/types"
+)
+
+// QueueAttributes is the configuration and state of a queue.
+type QueueAttributes struct {
+	QueueArn string
+	// The approximate numbers of visible, in-flight and delayed messages.
+	ApproximateNumberOfMessages           int64
+	ApproximateNumberOfMessagesNotVisible int64
+	ApproximateNumberOfMessagesDelayed    int64
+
+	VisibilityTimeout      time.Duration
+	MessageRetentionPeriod time.Duration
+	Delay                  time.Duration
+	ReceiveMessageWaitTime time.Duration
+	MaximumMessageSize     int
+	// RedrivePolicy is nil if the queue has no dead-letter queue.
+	RedrivePolicy      *RedrivePolicy
+	RedriveAllowPolicy string
+	Policy             string
+
+	KmsMasterKeyId        string
+	KmsDataKeyReusePeriod time.Duration
+	SqsManagedSSE         bool
+
+	FifoQueue                 bool
+	ContentBasedDeduplication bool
+	DeduplicationScope        string
+	FifoThroughputLimit       string
+
+	CreatedTimestamp      time.Time
+	LastModifiedTimestamp time.Time
+	// Raw are all the attributes as returned by AWS.
+	Raw map[string]string
+}
+
+// Attributes returns the configuration and the approximate message counts of the queue.
+func (q *Queue) Attributes(ctx context.Context) (*QueueAttributes, error) {
+	output, err := q.client.SQS.GetQueueAttributes(ctx, &sqs.GetQueueAttributesInput{
+		AttributeNames: []types.QueueAttributeName{types.QueueAttributeNameAll},
+		QueueUrl:       aws.String(q.queueUrl),
+	})
+	if err != nil {
+		return nil, fmt.Errorf("q.client.SQS.GetQueueAttributes: %w", err)
+	}
+
+	p := attributeParser{attributes: output.Attributes}
+	a := &QueueAttributes{
+		QueueArn:                              output.Attributes[NameQueueArn],
+		ApproximateNumberOfMessages:           p.int64("ApproximateNumberOfMessages"),
+		ApproximateNumberOfMessagesNotVisible: p.int64("ApproximateNumberOfMessagesNotVisible"),
+		ApproximateNumberOfMessagesDelayed:    p.int64("ApproximateNumberOfMessagesDelayed"),
+		VisibilityTimeout:                     p.seconds(QueueAttributeVisibilityTimeout),
+		MessageRetentionPeriod:                p.seconds(QueueAttributeMessageRetentionPeriod),
+		Delay:                                 p.seconds(QueueAttributeDelaySeconds),
+		ReceiveMessageWaitTime:                p.seconds(QueueAttributeReceiveMessageWaitTimeSeconds),
+		MaximumMessageSize:                    int(p.int64(QueueAttributeMaximumMessageSize)),
+		RedriveAllowPolicy:                    output.Attributes["RedriveAllowPolicy"],
+		Policy:                                output.Attributes[QueueAttributePolicy],
+		KmsMasterKeyId:                        output.Attributes[QueueAttributeKmsMasterKeyId],
+		KmsDataKeyReusePeriod:                 p.seconds(QueueAttributeKmsDataKeyReusePeriodSeconds),
+		SqsManagedSSE:                         p.bool(QueueAttributeSqsManagedSseEnabled),
+		FifoQueue:                             p.bool(QueueAttributeFifoQueue),
+		ContentBasedDeduplication:             p.bool(QueueAttributeContentBasedDeduplication),
+		DeduplicationScope:                    output.Attributes[QueueAttributeDeduplicationScope],
+		FifoThroughputLimit:                   output.Attributes[QueueAttributeFifoThroughputLimit],
+		CreatedTimestamp:                      p.epochSeconds("CreatedTimestamp"),
+		LastModifiedTimestamp:                 p.epochSeconds("LastModifiedTimestamp"),
+		Raw:                                   output.Attributes,
+	}
+	if a.RedrivePolicy, err = parseRedrivePolicy(output.Attributes[QueueAttributeRedrivePolicy]); err != nil {
+		return nil, fmt.Errorf("parseRedrivePolicy: %w", err)
+	}
+	if p.err != nil {
+		return nil, p.err
+	}
+
+	return a, nil
+}
+
+// SetAttributes validates the options and sets them on the queue. Zero fields are left unchanged;
+// set a zero value, e.g. a VisibilityTimeout of 0, through the Attributes of the options.
+func (q *Queue) SetAttributes(ctx context.Context, opts QueueOptions) error {
+	if err := opts.validate(q.queueName, false); err != nil {
+		return fmt.Errorf("opts.validate: %w", err)
+	}
+
+	attributes := opts.attributes()
+	if len(attributes) == 0 {
+		return nil
+	}
+	if _, err := q.client.SQS.SetQueueAttributes(ctx, &sqs.SetQueueAttributesInput{
+		QueueUrl:   aws.String(q.queueUrl),
+		Attributes: attributes,
+	}); err != nil {
+		return fmt.Errorf("q.client.SQS.SetQueueAttributes: %w", err)
+	}
+
+	return nil
+}
+
+// attributeParser converts attribute values, keeping the first error.
+// Missing attributes convert to zero values.
+type attributeParser struct {
+	attributes map[string]string
+	err        error
+}
+
+// int64 returns the attribute as an integer.
+func (p *attributeParser) int64(name string) int64 {
+	v, ok := p.attributes[name]
+	if !ok || v == "" {
+		return 0
+	}
+
+	n, err := strconv.ParseInt(v, 10, 64)
+	if err != nil && p.err == nil {
+		p.err = fmt.Errorf("strconv.ParseInt(%s) : %w", name, err)
+	}
+	return n
+}
+
+// bool returns the attribute as a boolean.
+func (p *attributeParser) bool(name string) bool {
+	v, ok := p.attributes[name]
+	if !ok || v == "" {
+		return false
+	}
+
+	b, err := strconv.ParseBool(v)
+	if err != nil && p.err == nil {
+		p.err = fmt.Errorf("strconv.ParseBool(%s) : %w", name, err)
+	}
+	return b
+}
+
+// seconds returns the attribute, a number of seconds, as a duration.
+func (p *attributeParser) seconds(name string) time.Duration {
+	return time.Duration(p.int64(name)) * time.Second
+}
+
+// epochSeconds returns the attribute, a Unix time in seconds, as a time.
+func (p *attributeParser) epochSeconds(name string) time.Time {
+	n := p.int64(name)
+	if n == 0 {
+		return time.Time{}
+	}
+
+	return time.Unix(n, 0)
+}
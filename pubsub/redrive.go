@@ -0,0 +1,310 @@
+package pubsub
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"path"
+	"regexp"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/sqs"
+	"github.com/aws/aws-sdk-go-v2/service/sqs/types"
+)
+
+// defaultRedriveHoldTimeout is the visibility timeout of the messages held during a redrive.
+const defaultRedriveHoldTimeout = 5 * time.Minute
+
+// RedriveOptions configures Redrive.
+type RedriveOptions struct {
+	// Destination receives the messages. If nil, each message goes to the queue recorded in its
+	// DeadLetterSourceQueue attribute, or else to the only queue whose redrive policy targets the dead-letter queue.
+	Destination *Queue
+	// RatePerSecond limits the number of messages moved per second. Zero means no limit.
+	RatePerSecond float64
+	// Limit is the maximum number of messages moved. Zero means no limit.
+	Limit int
+	// AttributeName and AttributeValue select messages whose string attribute has the value.
+	AttributeName  string
+	AttributeValue string
+	// BodyPattern selects messages whose body matches.
+	BodyPattern *regexp.Regexp
+	// DryRun reports the messages that would be moved without moving them.
+	DryRun bool
+	// HoldTimeout is the visibility timeout of skipped messages until the redrive ends. Defaults to 5 minutes.
+	HoldTimeout time.Duration
+}
+
+// RedriveMessage is a message selected by Redrive.
+type RedriveMessage struct {
+	MessageID   string
+	Body        string
+	Destination string
+	Moved       bool
+}
+
+// RedriveResult is the result of Redrive.
+type RedriveResult struct {
+	Messages []RedriveMessage
+	// Skipped is the number of messages that did not match the filters. Messages received after the limit is
+	// reached are released without being counted.
+	Skipped int
+}
+
+// Moved returns the number of messages moved.
+func (r *RedriveResult) Moved() int {
+	n := 0
+	for _, m := range r.Messages {
+		if m.Moved {
+			n++
+		}
+	}
+
+	return n
+}
+
+// match returns whether the message matches the filters of the options.
+func (o RedriveOptions) match(m types.Message) bool {
+	if o.AttributeName != "" {
+		v, ok := m.MessageAttributes[o.AttributeName]
+		if !ok || aws.ToString(v.StringValue) != o.AttributeValue {
+			return false
+		}
+	}
+	if o.BodyPattern != nil && !o.BodyPattern.MatchString(aws.ToString(m.Body)) {
+		return false
+	}
+
+	return true
+}
+
+// Redrive calls the RedriveContext method.
+func (q *Queue) Redrive(opts RedriveOptions) (*RedriveResult, error) {
+	return q.RedriveContext(context.Background(), opts)
+}
+
+// RedriveContext moves messages from the queue, a dead-letter queue, back to their source queue or to the
+// destination of the options. Messages are received until the queue returns no more, or only messages already
+// seen, and the messages that are skipped or previewed in a dry run are made visible again at the end.
+// Messages are counted once even if they are received again after the hold timeout.
+func (q *Queue) RedriveContext(ctx context.Context, opts RedriveOptions) (*RedriveResult, error) {
+	hold := opts.HoldTimeout
+	if hold <= 0 {
+		hold = defaultRedriveHoldTimeout
+	}
+
+	var interval time.Duration
+	if opts.RatePerSecond > 0 {
+		interval = time.Duration(float64(time.Second) / opts.RatePerSecond)
+	}
+
+	var (
+		result = &RedriveResult{}
+		// seen are the ids of the messages already handled, and held maps those left in the queue to their
+		// latest receipt handle. Held messages come back once the hold timeout expires during a long run.
+		seen         = make(map[string]bool)
+		held         = make(map[string]*string)
+		destinations = make(map[string]*Queue)
+		last         time.Time
+	)
+	// holdAll holds the messages of a batch left unhandled on an early return, so that they are released too.
+	holdAll := func(messages []types.Message) {
+		for _, m := range messages {
+			id := aws.ToString(m.MessageId)
+			if _, ok := held[id]; ok || !seen[id] {
+				held[id] = m.ReceiptHandle
+			}
+		}
+	}
+	defer func() {
+		for _, receiptHandle := range held {
+			_, _ = q.client.SQS.ChangeMessageVisibility(context.Background(), &sqs.ChangeMessageVisibilityInput{
+				QueueUrl:          aws.String(q.queueUrl),
+				ReceiptHandle:     receiptHandle,
+				VisibilityTimeout: 0,
+			})
+		}
+	}()
+
+	for opts.Limit == 0 || len(result.Messages) < opts.Limit {
+		output, err := q.client.SQS.ReceiveMessage(ctx, &sqs.ReceiveMessageInput{
+			QueueUrl:              aws.String(q.queueUrl),
+			MaxNumberOfMessages:   10,
+			VisibilityTimeout:     visibilityTimeoutSeconds(hold),
+			WaitTimeSeconds:       1,
+			AttributeNames:        []types.QueueAttributeName{types.QueueAttributeNameAll},
+			MessageAttributeNames: []string{string(types.QueueAttributeNameAll)},
+		})
+		if err != nil {
+			return result, fmt.Errorf("q.client.SQS.ReceiveMessage: %w", err)
+		}
+		if len(output.Messages) == 0 {
+			return result, nil
+		}
+
+		fresh := 0
+		for i, m := range output.Messages {
+			id := aws.ToString(m.MessageId)
+			if seen[id] {
+				if _, ok := held[id]; ok {
+					held[id] = m.ReceiptHandle
+				}
+				continue
+			}
+			seen[id] = true
+			fresh++
+
+			if opts.Limit > 0 && len(result.Messages) >= opts.Limit {
+				held[id] = m.ReceiptHandle
+				continue
+			}
+			if !opts.match(m) {
+				result.Skipped++
+				held[id] = m.ReceiptHandle
+				continue
+			}
+
+			dest, err := q.redriveDestination(ctx, opts, m, destinations)
+			if err != nil {
+				held[id] = m.ReceiptHandle
+				holdAll(output.Messages[i+1:])
+				return result, fmt.Errorf("q.redriveDestination(%s) : %w", id, err)
+			}
+
+			rm := RedriveMessage{MessageID: id, Body: aws.ToString(m.Body), Destination: dest.queueArn}
+			if opts.DryRun {
+				held[id] = m.ReceiptHandle
+				result.Messages = append(result.Messages, rm)
+				continue
+			}
+
+			if wait := interval - time.Since(last); interval > 0 && wait > 0 {
+				select {
+				case <-ctx.Done():
+					held[id] = m.ReceiptHandle
+					holdAll(output.Messages[i+1:])
+					return result, ctx.Err()
+				case <-time.After(wait):
+				}
+			}
+			last = time.Now()
+
+			if err := q.move(ctx, m, dest); err != nil {
+				held[id] = m.ReceiptHandle
+				holdAll(output.Messages[i+1:])
+				return result, fmt.Errorf("q.move(%s) : %w", id, err)
+			}
+			rm.Moved = true
+			result.Messages = append(result.Messages, rm)
+		}
+		if fresh == 0 {
+			// Only held messages came back: every message has been seen.
+			return result, nil
+		}
+	}
+
+	return result, nil
+}
+
+// redriveDestination returns the queue a message is moved to, caching the queues by ARN.
+func (q *Queue) redriveDestination(ctx context.Context, opts RedriveOptions, m types.Message, cache map[string]*Queue) (*Queue, error) {
+	if opts.Destination != nil {
+		return opts.Destination, nil
+	}
+
+	if v, ok := m.MessageAttributes[AttributeDeadLetterSourceQueue]; ok && aws.ToString(v.StringValue) != "" {
+		queueArn := aws.ToString(v.StringValue)
+		if dest, ok := cache[queueArn]; ok {
+			return dest, nil
+		}
+		dest, err := q.client.NewQueueContext(ctx, queueArn)
+		if err != nil {
+			return nil, fmt.Errorf("q.client.NewQueueContext: %w", err)
+		}
+		cache[queueArn] = dest
+		return dest, nil
+	}
+
+	const sourceKey = ""
+	if dest, ok := cache[sourceKey]; ok {
+		return dest, nil
+	}
+	sources, err := q.DeadLetterSourceQueues(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("q.DeadLetterSourceQueues: %w", err)
+	}
+	if len(sources) != 1 {
+		return nil, fmt.Errorf("found %d source queues, set a destination", len(sources))
+	}
+	cache[sourceKey] = sources[0]
+
+	return sources[0], nil
+}
+
+// move sends the message to the destination without the dead-letter failure attributes and deletes it from the queue.
+func (q *Queue) move(ctx context.Context, m types.Message, dest *Queue) error {
+	attributes := make(map[string]types.MessageAttributeValue, len(m.MessageAttributes))
+	for k, v := range m.MessageAttributes {
+		if !containsString(deadLetterAttributeNames, k) {
+			attributes[k] = v
+		}
+	}
+
+	if err := dest.Send(ctx, aws.ToString(m.Body), attributes); err != nil {
+		return fmt.Errorf("dest.Send: %w", err)
+	}
+	if _, err := q.client.SQS.DeleteMessage(ctx, &sqs.DeleteMessageInput{
+		QueueUrl:      aws.String(q.queueUrl),
+		ReceiptHandle: m.ReceiptHandle,
+	}); err != nil {
+		return fmt.Errorf("q.client.SQS.DeleteMessage: %w", err)
+	}
+
+	return nil
+}
+
+// DeadLetterSourceQueues returns the queues whose redrive policy targets the queue.
+func (q *Queue) DeadLetterSourceQueues(ctx context.Context) ([]*Queue, error) {
+	var queues []*Queue
+	paginator := sqs.NewListDeadLetterSourceQueuesPaginator(q.client.SQS, &sqs.ListDeadLetterSourceQueuesInput{
+		QueueUrl: aws.String(q.queueUrl),
+	})
+	for paginator.HasMorePages() {
+		page, err := paginator.NextPage(ctx)
+		if err != nil {
+			return nil, fmt.Errorf("paginator.NextPage: %w", err)
+		}
+		for _, queueUrl := range page.QueueUrls {
+			queue, err := q.client.newQueueFromUrl(ctx, queueUrl)
+			if err != nil {
+				return nil, fmt.Errorf("q.client.newQueueFromUrl(%s) : %w", queueUrl, err)
+			}
+			queues = append(queues, queue)
+		}
+	}
+
+	return queues, nil
+}
+
+// newQueueFromUrl returns an initialized queue client based on the queue url.
+func (c *PubsubClient) newQueueFromUrl(ctx context.Context, queueUrl string) (*Queue, error) {
+	atr, err := c.SQS.GetQueueAttributes(ctx, &sqs.GetQueueAttributesInput{
+		AttributeNames: []types.QueueAttributeName{types.QueueAttributeNameQueueArn},
+		QueueUrl:       aws.String(queueUrl),
+	})
+	if err != nil {
+		return nil, fmt.Errorf("c.SQS.GetQueueAttributes(queueUrl=%s) : %w", queueUrl, err)
+	}
+	queueArn := atr.Attributes[NameQueueArn]
+	if queueArn == "" {
+		return nil, errors.New("queue arn not found")
+	}
+
+	return &Queue{
+		client:    c,
+		queueArn:  queueArn,
+		queueName: path.Base(queueUrl),
+		queueUrl:  queueUrl,
+	}, nil
+}
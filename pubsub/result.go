@@ -0,0 +1,69 @@
+package pubsub
+
+import (
+	"errors"
+	"fmt"
+)
+
+// Outcome is what the consumer did with a received message.
+type Outcome string
+
+const (
+	OutcomeAcked        = Outcome("acked")
+	OutcomeRequeued     = Outcome("requeued")
+	OutcomeDropped      = Outcome("dropped")
+	OutcomeDeadLettered = Outcome("dead-lettered")
+)
+
+// MessageResult is the outcome of a single received message.
+type MessageResult struct {
+	MessageID string
+	Outcome   Outcome
+	// HandlerErr is the error returned by the handler.
+	HandlerErr error
+	// AckErr is the error of the DeleteMessage or ChangeMessageVisibility call that carried out the outcome.
+	AckErr error
+}
+
+// Redelivered returns whether the message will be received again because deleting or forwarding it failed.
+func (r MessageResult) Redelivered() bool {
+	return r.AckErr != nil && r.Outcome != OutcomeRequeued
+}
+
+// ConsumeResult is the outcome of a single poll of a queue.
+type ConsumeResult struct {
+	Queue    string
+	Messages []MessageResult
+}
+
+// Count returns the number of messages with the outcome.
+func (r *ConsumeResult) Count(outcome Outcome) int {
+	if r == nil {
+		return 0
+	}
+
+	n := 0
+	for _, m := range r.Messages {
+		if m.Outcome == outcome {
+			n++
+		}
+	}
+
+	return n
+}
+
+// Err returns the ack errors of every message joined, or nil.
+func (r *ConsumeResult) Err() error {
+	if r == nil {
+		return nil
+	}
+
+	var errs []error
+	for _, m := range r.Messages {
+		if m.AckErr != nil {
+			errs = append(errs, fmt.Errorf("message id %s: %w", m.MessageID, m.AckErr))
+		}
+	}
+
+	return errors.Join(errs...)
+}
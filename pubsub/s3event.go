@@ -0,0 +1,186 @@
+package pubsub
+
+import (
+	"fmt"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// S3EventName is the name of an Amazon S3 event notification, e.g. "ObjectCreated:Put".
+type S3EventName string
+
+const (
+	S3EventTest = S3EventName("s3:TestEvent")
+
+	S3EventObjectCreated                        = S3EventName("ObjectCreated:*")
+	S3EventObjectCreatedPut                     = S3EventName("ObjectCreated:Put")
+	S3EventObjectCreatedPost                    = S3EventName("ObjectCreated:Post")
+	S3EventObjectCreatedCopy                    = S3EventName("ObjectCreated:Copy")
+	S3EventObjectCreatedCompleteMultipartUpload = S3EventName("ObjectCreated:CompleteMultipartUpload")
+
+	S3EventObjectRemoved                    = S3EventName("ObjectRemoved:*")
+	S3EventObjectRemovedDelete              = S3EventName("ObjectRemoved:Delete")
+	S3EventObjectRemovedDeleteMarkerCreated = S3EventName("ObjectRemoved:DeleteMarkerCreated")
+
+	S3EventObjectRestore          = S3EventName("ObjectRestore:*")
+	S3EventObjectRestorePost      = S3EventName("ObjectRestore:Post")
+	S3EventObjectRestoreCompleted = S3EventName("ObjectRestore:Completed")
+	S3EventObjectRestoreDelete    = S3EventName("ObjectRestore:Delete")
+
+	S3EventObjectTagging       = S3EventName("ObjectTagging:*")
+	S3EventObjectTaggingPut    = S3EventName("ObjectTagging:Put")
+	S3EventObjectTaggingDelete = S3EventName("ObjectTagging:Delete")
+
+	S3EventObjectAclPut = S3EventName("ObjectAcl:Put")
+
+	S3EventLifecycleExpiration                    = S3EventName("LifecycleExpiration:*")
+	S3EventLifecycleExpirationDelete              = S3EventName("LifecycleExpiration:Delete")
+	S3EventLifecycleExpirationDeleteMarkerCreated = S3EventName("LifecycleExpiration:DeleteMarkerCreated")
+	S3EventLifecycleTransition                    = S3EventName("LifecycleTransition")
+
+	S3EventReplication        = S3EventName("Replication:*")
+	S3EventIntelligentTiering = S3EventName("IntelligentTiering")
+
+	S3EventReducedRedundancyLostObject = S3EventName("ReducedRedundancyLostObject")
+)
+
+// s3EventNamePrefix is the prefix used in bucket notification configurations but not in event records.
+const s3EventNamePrefix = "s3:"
+
+// Matches reports whether the event name matches the pattern.
+// The pattern may end with "*" to match every event of a type, e.g. ObjectCreated:*, and the "s3:" prefix is ignored.
+func (n S3EventName) Matches(pattern S3EventName) bool {
+	name := strings.TrimPrefix(string(n), s3EventNamePrefix)
+	p := strings.TrimPrefix(string(pattern), s3EventNamePrefix)
+	if prefix, ok := strings.CutSuffix(p, "*"); ok {
+		return strings.HasPrefix(name, prefix)
+	}
+
+	return name == p
+}
+
+// S3Event is the struct to map when sending messages to the queue via s3.
+// When notifications are configured, Amazon S3 sends a test message that has no records and fills the
+// Service, Event, Time, Bucket, RequestId and HostId fields instead.
+type S3Event struct {
+	Records []S3EventRecord
+
+	Service   string      `json:",omitempty"`
+	Event     S3EventName `json:",omitempty"`
+	Time      string      `json:",omitempty"`
+	Bucket    string      `json:",omitempty"`
+	RequestId string      `json:",omitempty"`
+	HostId    string      `json:",omitempty"`
+}
+
+// IsTestEvent returns whether the event is the s3:TestEvent message.
+func (e S3Event) IsTestEvent() bool {
+	return e.Event == S3EventTest
+}
+
+// S3EventRecord is a single record of an S3Event.
+type S3EventRecord struct {
+	AwsRegion         string                 `json:"awsRegion"`
+	EventName         S3EventName            `json:"eventName"`
+	EventTime         string                 `json:"eventTime"`
+	EventSource       string                 `json:"eventSource"`
+	EventVersion      string                 `json:"eventVersion"`
+	UserIdentity      map[string]string      `json:"userIdentity"`
+	RequestParameters map[string]interface{} `json:"requestParameters"`
+	ResponseElements  map[string]interface{} `json:"responseElements"`
+	S3                S3Entity               `json:"s3"`
+}
+
+// ParsedEventTime returns the EventTime of the record as time.Time.
+func (r S3EventRecord) ParsedEventTime() (time.Time, error) {
+	t, err := time.Parse(time.RFC3339Nano, r.EventTime)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("time.Parse(%s) : %w", r.EventTime, err)
+	}
+
+	return t, nil
+}
+
+// S3Entity is the s3 element of an S3EventRecord.
+type S3Entity struct {
+	S3SchemaVersion string   `json:"s3SchemaVersion"`
+	ConfigurationId string   `json:"configurationId"`
+	Bucket          S3Bucket `json:"bucket"`
+	Object          S3Object `json:"object"`
+}
+
+// S3Bucket is the bucket the event occurred in.
+type S3Bucket struct {
+	Name          string                 `json:"name"`
+	OwnerIdentity map[string]interface{} `json:"ownerIdentity"`
+	ARN           string                 `json:"arn"`
+}
+
+// S3Object is the object the event occurred on.
+type S3Object struct {
+	Key       string `json:"key"`
+	Size      int64  `json:"size"`
+	ETag      string `json:"etag"`
+	VersionId string `json:"versionId,omitempty"`
+	Sequencer string `json:"sequencer"`
+}
+
+// DecodedKey returns the object key decoded from the URL encoding used in event notifications,
+// where spaces are sent as "+".
+func (o S3Object) DecodedKey() (string, error) {
+	key, err := url.QueryUnescape(o.Key)
+	if err != nil {
+		return "", fmt.Errorf("url.QueryUnescape(%s) : %w", o.Key, err)
+	}
+
+	return key, nil
+}
+
+// S3Filter selects the records of an S3Event. Empty fields match every record.
+type S3Filter struct {
+	Buckets []string
+	Prefix  string
+	Suffix  string
+	Events  []S3EventName
+}
+
+// Match returns whether the record matches the filter. Prefix and Suffix are compared against the decoded key.
+func (f S3Filter) Match(r S3EventRecord) bool {
+	if len(f.Buckets) > 0 && !containsString(f.Buckets, r.S3.Bucket.Name) {
+		return false
+	}
+	if len(f.Events) > 0 {
+		matched := false
+		for _, e := range f.Events {
+			if r.EventName.Matches(e) {
+				matched = true
+				break
+			}
+		}
+		if !matched {
+			return false
+		}
+	}
+	if f.Prefix == "" && f.Suffix == "" {
+		return true
+	}
+
+	key, err := r.S3.Object.DecodedKey()
+	if err != nil {
+		key = r.S3.Object.Key
+	}
+
+	return strings.HasPrefix(key, f.Prefix) && strings.HasSuffix(key, f.Suffix)
+}
+
+// containsString returns whether s is in ss.
+func containsString(ss []string, s string) bool {
+	for _, v := range ss {
+		if v == s {
+			return true
+		}
+	}
+
+	return false
+}
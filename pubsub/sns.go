@@ -3,7 +3,6 @@ package pubsub
 import (
 	"context"
 	"fmt"
-	"log"
 
 	"github.com/aws/aws-sdk-go-v2/aws"
 	"github.com/aws/aws-sdk-go-v2/service/sns"
@@ -37,17 +36,58 @@ func (t *Topic) Exist(ctx context.Context) (bool, error) {
 	return true, nil
 }
 
+// Arn returns the topic arn.
+func (t *Topic) Arn() string {
+	return t.topicArn
+}
+
+// Name returns the topic name.
+func (t *Topic) Name() string {
+	return t.topicName
+}
+
 // Publish sends a message to an Amazon SNS topic, a text message.
 func (t *Topic) Publish(ctx context.Context, message string, attributes map[string]types.MessageAttributeValue) error {
 	m, err := t.client.SNS.Publish(ctx, &sns.PublishInput{
 		Message:           aws.String(message),
-		MessageAttributes: attributes,
+		MessageAttributes: injectSNSTrace(ctx, attributes),
 		TopicArn:          &t.topicArn,
 	})
+	t.client.metrics().Published(t.topicName, err)
 	if err != nil {
 		return fmt.Errorf("t.client.SNS.Publish: %w", err)
 	}
 
-	log.Default().Printf("message id: %s", *m.MessageId)
+	t.client.logger().DebugContext(ctx, "message published", "topic", t.topicName, "message_id", aws.ToString(m.MessageId))
 	return nil
 }
+
+// Delete deletes the topic and, on the SNS side, its subscriptions. Subscribed queues are left untouched;
+// use Teardown to delete them too.
+func (t *Topic) Delete(ctx context.Context) error {
+	if _, err := t.client.SNS.DeleteTopic(ctx, &sns.DeleteTopicInput{
+		TopicArn: &t.topicArn,
+	}); err != nil {
+		return fmt.Errorf("t.client.SNS.DeleteTopic: %w", err)
+	}
+
+	t.client.logger().InfoContext(ctx, "topic deleted", "topic", t.topicName)
+	return nil
+}
+
+// Unsubscribe deletes the subscription. The queue is left untouched.
+func (s *Subscription) Unsubscribe(ctx context.Context) error {
+	if _, err := s.client.SNS.Unsubscribe(ctx, &sns.UnsubscribeInput{
+		SubscriptionArn: &s.subscriptionArn,
+	}); err != nil {
+		return fmt.Errorf("s.client.SNS.Unsubscribe: %w", err)
+	}
+
+	s.client.logger().InfoContext(ctx, "unsubscribed", "topic", s.topic.topicName, "queue", s.queue.queueName)
+	return nil
+}
+
+// Arn returns the subscription arn.
+func (s *Subscription) Arn() string {
+	return s.subscriptionArn
+}
@@ -3,8 +3,10 @@ package pubsub
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
-	"log"
+	"strconv"
+	"time"
 
 	"github.com/aws/aws-sdk-go-v2/aws"
 	"github.com/aws/aws-sdk-go-v2/service/sqs"
@@ -18,6 +20,9 @@ type Queue struct {
 	queueArn  string
 	queueName string
 	queueUrl  string
+
+	middlewares []Middleware
+	deadLetter  *DeadLetterOptions
 }
 
 // SNSEvent is the struct to map when sending messages to the queue via topic.
@@ -35,35 +40,6 @@ type SNSEvent struct {
 	MessageAttributes map[string]map[string]string
 }
 
-// S3Event is the struct to map when sending messages to the queue via s3.
-type S3Event struct {
-	Records []struct {
-		AwsRegion         string                 `json:"awsRegion"`
-		EventName         string                 `json:"eventName"`
-		EventTime         string                 `json:"eventTime"`
-		EventSource       string                 `json:"eventSource"`
-		EventVersion      string                 `json:"eventVersion"`
-		UserIdentity      map[string]string      `json:"userIdentity"`
-		RequestParameters map[string]interface{} `json:"requestParameters"`
-		ResponseElements  map[string]interface{} `json:"responseElements"`
-		S3                struct {
-			S3SchemaVersion string `json:"s3SchemaVersion"`
-			ConfigurationId string `json:"configurationId"`
-			Bucket          struct {
-				Name          string                 `json:"name"`
-				OwnerIdentity map[string]interface{} `json:"ownerIdentity"`
-				ARN           string                 `json:"arn"`
-			} `json:"bucket"`
-			Object struct {
-				Key       string `json:"key"`
-				Size      int64  `json:"size"`
-				ETag      string `json:"etag"`
-				Sequencer string `json:"sequencer"`
-			} `json:"object"`
-		} `json:"s3"`
-	}
-}
-
 // Exist returns whether the topic exists or not.
 func (q *Queue) Exist(ctx context.Context) (bool, error) {
 	if _, err := q.client.SQS.GetQueueAttributes(ctx, &sqs.GetQueueAttributesInput{
@@ -75,108 +51,424 @@ func (q *Queue) Exist(ctx context.Context) (bool, error) {
 	return true, nil
 }
 
+// Arn returns the queue arn.
+func (q *Queue) Arn() string {
+	return q.queueArn
+}
+
+// Name returns the queue name.
+func (q *Queue) Name() string {
+	return q.queueName
+}
+
+// Url returns the queue url.
+func (q *Queue) Url() string {
+	return q.queueUrl
+}
+
 // Send delivers a message to the specified queue.
 func (q *Queue) Send(ctx context.Context, message string, attributes map[string]types.MessageAttributeValue) error {
 	m, err := q.client.SQS.SendMessage(ctx, &sqs.SendMessageInput{
 		MessageBody:       aws.String(message),
-		MessageAttributes: attributes,
+		MessageAttributes: injectSQSTrace(ctx, attributes),
 		QueueUrl:          &q.queueUrl,
 	})
+	q.client.metrics().Sent(q.queueName, err)
 	if err != nil {
 		return fmt.Errorf("q.client.SQS.SendMessage: %w", err)
 	}
 
-	log.Default().Printf("message id: %s", *m.MessageId)
+	q.client.logger().DebugContext(ctx, "message sent", "queue", q.queueName, "message_id", aws.ToString(m.MessageId))
+	return nil
+}
+
+// Receive receives up to maxMessages messages without handling them.
+// The messages stay invisible for the visibility timeout of the queue until they are acked or released.
+func (q *Queue) Receive(ctx context.Context, maxMessages, waitTimeSeconds int32) ([]*Message, error) {
+	output, err := q.client.SQS.ReceiveMessage(ctx, &sqs.ReceiveMessageInput{
+		QueueUrl:              aws.String(q.queueUrl),
+		MaxNumberOfMessages:   maxMessages,
+		WaitTimeSeconds:       waitTimeSeconds,
+		AttributeNames:        []types.QueueAttributeName{types.QueueAttributeNameAll},
+		MessageAttributeNames: []string{string(types.QueueAttributeNameAll)},
+	})
+	if err != nil {
+		return nil, fmt.Errorf("q.client.SQS.ReceiveMessage: %w", err)
+	}
+
+	messages := make([]*Message, 0, len(output.Messages))
+	for _, m := range output.Messages {
+		messages = append(messages, newMessage(q, m))
+	}
+
+	return messages, nil
+}
+
+// Ack deletes a received message.
+func (q *Queue) Ack(ctx context.Context, m *Message) error {
+	if _, err := q.client.SQS.DeleteMessage(ctx, &sqs.DeleteMessageInput{
+		QueueUrl:      aws.String(q.queueUrl),
+		ReceiptHandle: aws.String(m.ReceiptHandle),
+	}); err != nil {
+		return fmt.Errorf("q.client.SQS.DeleteMessage: %w", err)
+	}
+
+	return nil
+}
+
+// Release makes a received message visible again immediately.
+func (q *Queue) Release(ctx context.Context, m *Message) error {
+	if _, err := q.client.SQS.ChangeMessageVisibility(ctx, &sqs.ChangeMessageVisibilityInput{
+		QueueUrl:          aws.String(q.queueUrl),
+		ReceiptHandle:     aws.String(m.ReceiptHandle),
+		VisibilityTimeout: 0,
+	}); err != nil {
+		return fmt.Errorf("q.client.SQS.ChangeMessageVisibility: %w", err)
+	}
+
+	return nil
+}
+
+// Purge deletes every message in the queue.
+func (q *Queue) Purge(ctx context.Context) error {
+	if _, err := q.client.SQS.PurgeQueue(ctx, &sqs.PurgeQueueInput{
+		QueueUrl: aws.String(q.queueUrl),
+	}); err != nil {
+		return fmt.Errorf("q.client.SQS.PurgeQueue: %w", err)
+	}
+
+	return nil
+}
+
+// Delete deletes the queue. Unless force is true, a queue with messages is not deleted and
+// an error wrapping ErrQueueNotEmpty is returned.
+func (q *Queue) Delete(ctx context.Context, force bool) error {
+	if !force {
+		if err := q.checkEmpty(ctx); err != nil {
+			return err
+		}
+	}
+
+	if _, err := q.client.SQS.DeleteQueue(ctx, &sqs.DeleteQueueInput{
+		QueueUrl: aws.String(q.queueUrl),
+	}); err != nil {
+		return fmt.Errorf("q.client.SQS.DeleteQueue: %w", err)
+	}
+
+	q.client.logger().InfoContext(ctx, "queue deleted", "queue", q.queueName)
+	return nil
+}
+
+// checkEmpty returns an error wrapping ErrQueueNotEmpty if the queue has visible, in-flight or delayed messages.
+// The counts are approximate, so a queue that received messages within the last minute may look empty.
+func (q *Queue) checkEmpty(ctx context.Context) error {
+	output, err := q.client.SQS.GetQueueAttributes(ctx, &sqs.GetQueueAttributesInput{
+		AttributeNames: []types.QueueAttributeName{
+			types.QueueAttributeNameApproximateNumberOfMessages,
+			types.QueueAttributeNameApproximateNumberOfMessagesNotVisible,
+			types.QueueAttributeNameApproximateNumberOfMessagesDelayed,
+		},
+		QueueUrl: aws.String(q.queueUrl),
+	})
+	if err != nil {
+		return fmt.Errorf("q.client.SQS.GetQueueAttributes: %w", err)
+	}
+
+	var n int64
+	for _, v := range output.Attributes {
+		count, err := strconv.ParseInt(v, 10, 64)
+		if err != nil {
+			return fmt.Errorf("strconv.ParseInt(%s) : %w", v, err)
+		}
+		n += count
+	}
+	if n > 0 {
+		return fmt.Errorf("queue %s has %d messages: %w", q.queueName, n, ErrQueueNotEmpty)
+	}
+
 	return nil
 }
 
 // Consume calls the consume method.
-func (q *Queue) Consume(ctx context.Context, handler func(c context.Context, message string) (bool, error)) error {
-	return q.consume(ctx, func(ctx context.Context, m types.Message) (bool, error) {
+// The handler error is classified with Retry, RetryAfter, Drop or DeadLetter; wrap a handler
+// returning (retryable bool, err error) with FromRetryable.
+func (q *Queue) Consume(ctx context.Context, handler func(c context.Context, message string) error) (*ConsumeResult, error) {
+	return q.consume(ctx, func(ctx context.Context, m types.Message) error {
 		return handler(ctx, *m.Body)
 	})
 }
 
+// ConsumeMessage calls the consume method with the message and its metadata.
+func (q *Queue) ConsumeMessage(ctx context.Context, handler func(c context.Context, message *Message) error) (*ConsumeResult, error) {
+	return q.consume(ctx, func(ctx context.Context, m types.Message) error {
+		message, ok := MessageFromContext(ctx)
+		if !ok {
+			message = newMessage(q, m)
+		}
+		return handler(ctx, message)
+	})
+}
+
 // ConsumeViaSNS maps the message to an SNSEvent struct and calls the consume method.
-func (q *Queue) ConsumeViaSNS(ctx context.Context, handler func(c context.Context, event SNSEvent) (retryable bool, err error)) error {
-	return q.consume(ctx, func(ctx context.Context, m types.Message) (bool, error) {
+func (q *Queue) ConsumeViaSNS(ctx context.Context, handler func(c context.Context, event SNSEvent) error) (*ConsumeResult, error) {
+	return q.consume(ctx, func(ctx context.Context, m types.Message) error {
 		var event SNSEvent
 		err := json.Unmarshal([]byte(*m.Body), &event)
 		if err != nil {
-			log.Default().Printf("failed to unmarshal json, body: %s", *m.Body)
-			return true, fmt.Errorf("json.Unmarshal: %w", err)
+			q.logDecodeFailure(ctx, m, *m.Body, err)
+			return DeadLetter(fmt.Errorf("json.Unmarshal: %w", err))
 		}
 		return handler(ctx, event)
 	})
 }
 
 // ConsumeViaS3 maps the message to an S3Event struct and calls the consume method.
-func (q *Queue) ConsumeViaS3(ctx context.Context, handler func(c context.Context, event S3Event) (retryable bool, err error)) error {
-	return q.consume(ctx, func(ctx context.Context, m types.Message) (bool, error) {
+// The s3:TestEvent message is deleted without calling the handler.
+func (q *Queue) ConsumeViaS3(ctx context.Context, handler func(c context.Context, event S3Event) error) (*ConsumeResult, error) {
+	return q.consume(ctx, func(ctx context.Context, m types.Message) error {
 		var event S3Event
 		err := json.Unmarshal([]byte(*m.Body), &event)
 		if err != nil {
-			log.Default().Printf("failed to unmarshal json, body: %s", *m.Body)
-			return true, fmt.Errorf("json.Unmarshal: %w", err)
+			q.logDecodeFailure(ctx, m, *m.Body, err)
+			return DeadLetter(fmt.Errorf("json.Unmarshal: %w", err))
+		}
+		if event.IsTestEvent() {
+			return nil
 		}
 		return handler(ctx, event)
 	})
 }
 
+// ConsumeViaEventBridge maps the message to an EventBridgeEvent struct with a raw detail and calls the consume method.
+// Use ConsumeEventBridge for a typed detail, or pass EventBridgeRouter.Dispatch as the handler to route events.
+func (q *Queue) ConsumeViaEventBridge(ctx context.Context, handler func(c context.Context, event EventBridgeEvent[json.RawMessage]) error) (*ConsumeResult, error) {
+	return ConsumeEventBridge(ctx, q, handler)
+}
+
+// ConsumeS3ViaSNS maps the message to an SNSEvent struct whose Message is an S3Event, and calls the handler
+// for each record that matches the filter. The message is retried if any failed record is retried.
+// The s3:TestEvent message is deleted without calling the handler.
+func (q *Queue) ConsumeS3ViaSNS(ctx context.Context, filter S3Filter, handler func(c context.Context, record S3EventRecord) error) (*ConsumeResult, error) {
+	return q.consume(ctx, func(ctx context.Context, m types.Message) error {
+		var envelope SNSEvent
+		if err := json.Unmarshal([]byte(*m.Body), &envelope); err != nil {
+			q.logDecodeFailure(ctx, m, *m.Body, err)
+			return DeadLetter(fmt.Errorf("json.Unmarshal: %w", err))
+		}
+
+		var event S3Event
+		if err := json.Unmarshal([]byte(envelope.Message), &event); err != nil {
+			q.logDecodeFailure(ctx, m, envelope.Message, err)
+			return DeadLetter(fmt.Errorf("json.Unmarshal: %w", err))
+		}
+		if event.IsTestEvent() {
+			return nil
+		}
+
+		return dispatchS3Records(ctx, event, filter, handler)
+	})
+}
+
+// ConsumeAny detects the envelope of each message, unwraps it into an Event and calls the consume method.
+// The s3:TestEvent message is deleted without calling the handler.
+func (q *Queue) ConsumeAny(ctx context.Context, handler func(c context.Context, event Event) error) (*ConsumeResult, error) {
+	return q.consume(ctx, func(ctx context.Context, m types.Message) error {
+		event, err := DetectEvent(*m.Body)
+		if err != nil {
+			q.logDecodeFailure(ctx, m, *m.Body, err)
+			return DeadLetter(fmt.Errorf("DetectEvent: %w", err))
+		}
+		if event.S3 != nil && event.S3.IsTestEvent() {
+			return nil
+		}
+		return handler(ctx, event)
+	})
+}
+
+// dispatchS3Records calls the handler for each record of the event that matches the filter.
+// The joined error is retried if any record error is retried, and otherwise takes the action of the first record error.
+func dispatchS3Records(ctx context.Context, event S3Event, filter S3Filter, handler func(context.Context, S3EventRecord) error) error {
+	var (
+		retry bool
+		errs  []error
+	)
+	for _, record := range event.Records {
+		if !filter.Match(record) {
+			continue
+		}
+		if err := handler(ctx, record); err != nil {
+			if action, _ := Classify(err); action == ActionRetry {
+				retry = true
+			}
+			errs = append(errs, fmt.Errorf("record %s/%s: %w", record.S3.Bucket.Name, record.S3.Object.Key, err))
+		}
+	}
+
+	err := errors.Join(errs...)
+	if retry {
+		return Retry(err)
+	}
+
+	return err
+}
+
+// handle calls f, converting a panic into a PanicError, and reports a failure to the error hook.
+func (q *Queue) handle(ctx context.Context, f MessageHandler, m types.Message) (err error) {
+	start := time.Now()
+	defer func() {
+		if r := recover(); r != nil {
+			perr := newPanicError(r)
+			err = Retry(perr)
+			if q.client.PanicRetryable != nil && !q.client.PanicRetryable(m, perr) {
+				err = DeadLetter(perr)
+			}
+		}
+		q.client.metrics().Handled(q.queueName, time.Since(start), err)
+		if err != nil && q.client.ErrorHook != nil {
+			q.client.ErrorHook(ctx, m, err)
+		}
+	}()
+
+	return f(ctx, m)
+}
+
+// maxVisibilityTimeout is the maximum visibility timeout of a message.
+const maxVisibilityTimeout = 12 * time.Hour
+
+// visibilityTimeoutSeconds rounds a positive delay up to whole seconds, so that a delay under a second does not
+// redeliver the message at once, and caps it at the maximum visibility timeout.
+func visibilityTimeoutSeconds(d time.Duration) int32 {
+	if d > maxVisibilityTimeout {
+		d = maxVisibilityTimeout
+	}
+
+	return int32((d + time.Second - 1) / time.Second)
+}
+
+// settle deletes, requeues or leaves the message according to the handler error.
+// It returns the outcome and the error of the call that carried it out.
+func (q *Queue) settle(ctx context.Context, m types.Message, handlerErr error) (Outcome, error) {
+	if handlerErr == nil {
+		if _, err := q.client.SQS.DeleteMessage(ctx, &sqs.DeleteMessageInput{
+			QueueUrl:      aws.String(q.queueUrl),
+			ReceiptHandle: m.ReceiptHandle,
+		}); err != nil {
+			q.client.metrics().AckFailed(q.queueName)
+			return OutcomeAcked, fmt.Errorf("q.SQS.DeleteMessage: %w", err)
+		}
+		q.client.metrics().Acked(q.queueName)
+
+		return OutcomeAcked, nil
+	}
+
+	action, delay := Classify(handlerErr)
+	if q.forwards(action) {
+		if err := q.forwardToDeadLetter(ctx, m, handlerErr); err != nil {
+			q.client.metrics().AckFailed(q.queueName)
+			return OutcomeDeadLettered, fmt.Errorf("q.forwardToDeadLetter: %w", err)
+		}
+		q.client.metrics().DeadLettered(q.queueName)
+
+		return OutcomeDeadLettered, nil
+	}
+	if action == ActionDeadLetter {
+		// Without a redrive policy, SQS would redeliver the message forever, so it is deleted instead.
+		deadLetterTargetArn, err := q.deadLetterTargetArn(ctx)
+		if err != nil {
+			q.client.metrics().AckFailed(q.queueName)
+			return OutcomeDeadLettered, fmt.Errorf("q.deadLetterTargetArn: %w", err)
+		}
+		if deadLetterTargetArn == "" {
+			action = ActionDrop
+		}
+	}
+
+	switch action {
+	case ActionDrop:
+		if _, err := q.client.SQS.DeleteMessage(ctx, &sqs.DeleteMessageInput{
+			QueueUrl:      aws.String(q.queueUrl),
+			ReceiptHandle: m.ReceiptHandle,
+		}); err != nil {
+			q.client.metrics().AckFailed(q.queueName)
+			return OutcomeDropped, fmt.Errorf("q.SQS.DeleteMessage: %w", err)
+		}
+		q.client.metrics().Dropped(q.queueName)
+
+		return OutcomeDropped, nil
+	case ActionDeadLetter:
+		q.client.metrics().DeadLettered(q.queueName)
+
+		return OutcomeDeadLettered, nil
+	}
+
+	visibilityTimeout := q.client.Config.RequeueVisibilityTimeout
+	if delay > 0 {
+		visibilityTimeout = visibilityTimeoutSeconds(delay)
+	}
+	if _, err := q.client.SQS.ChangeMessageVisibility(ctx, &sqs.ChangeMessageVisibilityInput{
+		QueueUrl:          aws.String(q.queueUrl),
+		ReceiptHandle:     m.ReceiptHandle,
+		VisibilityTimeout: visibilityTimeout,
+	}); err != nil {
+		q.client.metrics().AckFailed(q.queueName)
+		return OutcomeRequeued, fmt.Errorf("q.SQS.ChangeMessageVisibility: %w", err)
+	}
+	q.client.metrics().Requeued(q.queueName)
+
+	return OutcomeRequeued, nil
+}
+
 // consume receives a message from a specific queue and executes the argument f function to delete the message.
 // It can also retry by changing the visibility timeout of the specified message in the queue to a new value.
-func (q *Queue) consume(ctx context.Context, f func(context.Context, types.Message) (bool, error)) error {
+// The result lists the outcome of every received message and is passed to the result hook; the error joins
+// the ack errors of every message.
+func (q *Queue) consume(ctx context.Context, f MessageHandler) (*ConsumeResult, error) {
+	f = q.handler(f)
+
 	params := &sqs.ReceiveMessageInput{
-		QueueUrl:            aws.String(q.queueUrl),
-		MaxNumberOfMessages: q.client.Config.MaxNumberOfMessages,
-		WaitTimeSeconds:     q.client.Config.WaitTimeSeconds,
+		QueueUrl:              aws.String(q.queueUrl),
+		MaxNumberOfMessages:   q.client.Config.MaxNumberOfMessages,
+		WaitTimeSeconds:       q.client.Config.WaitTimeSeconds,
+		AttributeNames:        []types.QueueAttributeName{types.QueueAttributeNameAll},
+		MessageAttributeNames: []string{string(types.QueueAttributeNameAll)},
 	}
 
 	output, err := q.client.SQS.ReceiveMessage(ctx, params)
 	if err != nil {
-		return fmt.Errorf("q.SQS.ReceiveMessage: %w", err)
+		q.client.metrics().ReceiveFailed(q.queueName)
+		return nil, fmt.Errorf("q.SQS.ReceiveMessage: %w", err)
 	}
+	q.client.metrics().Received(q.queueName, len(output.Messages))
 
+	result := &ConsumeResult{
+		Queue:    q.queueName,
+		Messages: make([]MessageResult, len(output.Messages)),
+	}
 	group, _ := errgroup.WithContext(ctx)
-	for _, message := range output.Messages {
-		group.Go(func(m types.Message) func() error {
+	for i, message := range output.Messages {
+		group.Go(func(i int, m types.Message) func() error {
 			return func() error {
-				retryable, err := f(ctx, m)
-
-				if err == nil {
-					if _, err := q.client.SQS.DeleteMessage(ctx, &sqs.DeleteMessageInput{
-						QueueUrl:      aws.String(q.queueUrl),
-						ReceiptHandle: m.ReceiptHandle,
-					}); err != nil {
-						return fmt.Errorf("q.SQS.DeleteMessage: %w", err)
-					}
-
-					return nil
-				}
-
-				if retryable {
-					if _, err := q.client.SQS.ChangeMessageVisibility(ctx, &sqs.ChangeMessageVisibilityInput{
-						QueueUrl:          aws.String(q.queueUrl),
-						ReceiptHandle:     m.ReceiptHandle,
-						VisibilityTimeout: q.client.Config.RequeueVisibilityTimeout,
-					}); err != nil {
-						return fmt.Errorf("q.SQS.ChangeMessageVisibility: %w", err)
-					}
-				} else {
-					if _, err := q.client.SQS.DeleteMessage(ctx, &sqs.DeleteMessageInput{
-						QueueUrl:      aws.String(q.queueUrl),
-						ReceiptHandle: m.ReceiptHandle,
-					}); err != nil {
-						return fmt.Errorf("q.SQS.DeleteMessage: %w", err)
-					}
+				ctx := contextWithMessage(extractTrace(ctx, m), newMessage(q, m))
+				handlerErr := q.handle(ctx, f, m)
+				outcome, ackErr := q.settle(ctx, m, handlerErr)
+				result.Messages[i] = MessageResult{
+					MessageID:  aws.ToString(m.MessageId),
+					Outcome:    outcome,
+					HandlerErr: handlerErr,
+					AckErr:     ackErr,
 				}
 				return nil
 			}
-		}(message))
+		}(i, message))
+	}
+	_ = group.Wait()
+
+	if q.client.ResultHook != nil {
+		q.client.ResultHook(ctx, result)
 	}
-	if err := group.Wait(); err != nil {
-		return fmt.Errorf("group.Wait: %w", err)
+	if err := result.Err(); err != nil {
+		return result, fmt.Errorf("result.Err: %w", err)
 	}
 
-	return nil
+	return result, nil
 }
@@ -0,0 +1,210 @@
+package pubsub
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	snstypes "github.com/aws/aws-sdk-go-v2/service/sns/types"
+	"github.com/aws/aws-sdk-go-v2/service/sqs/types"
+)
+
+// emptyFilterPolicy is the filter policy value that removes the filter policy of a subscription.
+const emptyFilterPolicy = "{}"
+
+// Topic returns the topic of the subscription.
+func (s *Subscription) Topic() *Topic {
+	return &s.topic
+}
+
+// Queue returns the queue of the subscription. Middlewares and dead-letter options set on it apply to
+// the Consume methods of the subscription.
+func (s *Subscription) Queue() *Queue {
+	return &s.queue
+}
+
+// Exist returns whether the subscription exists or not.
+func (s *Subscription) Exist(ctx context.Context) (bool, error) {
+	if _, err := s.Attributes(ctx); err != nil {
+		return false, fmt.Errorf("s.Attributes: %w", err)
+	}
+
+	return true, nil
+}
+
+// Publish sends a message to the topic of the subscription.
+func (s *Subscription) Publish(ctx context.Context, message string, attributes map[string]snstypes.MessageAttributeValue) error {
+	return s.topic.Publish(ctx, message, attributes)
+}
+
+// Consume consumes the queue of the subscription and calls the handler with the message published to the topic.
+// Messages are unwrapped from the SNS envelope, and passed as is when the subscription has raw message delivery.
+func (s *Subscription) Consume(ctx context.Context, handler func(c context.Context, message string) error) (*ConsumeResult, error) {
+	return s.ConsumeViaSNS(ctx, func(ctx context.Context, event SNSEvent) error {
+		return handler(ctx, event.Message)
+	})
+}
+
+// ConsumeMessage calls the consume method with the message and its metadata, its Body unwrapped from the SNS envelope.
+func (s *Subscription) ConsumeMessage(ctx context.Context, handler func(c context.Context, message *Message) error) (*ConsumeResult, error) {
+	q := &s.queue
+	return q.consume(ctx, func(ctx context.Context, m types.Message) error {
+		event, err := s.unwrap(ctx, m)
+		if err != nil {
+			return err
+		}
+
+		message, ok := MessageFromContext(ctx)
+		if !ok {
+			message = newMessage(q, m)
+		}
+		unwrapped := *message
+		unwrapped.Body = event.Message
+		return handler(ctx, &unwrapped)
+	})
+}
+
+// ConsumeViaSNS maps the message to an SNSEvent struct and calls the consume method.
+// With raw message delivery, the event is built from the message: its Message is the body, its TopicArn
+// the topic of the subscription and its MessageAttributes the message attributes.
+func (s *Subscription) ConsumeViaSNS(ctx context.Context, handler func(c context.Context, event SNSEvent) error) (*ConsumeResult, error) {
+	return s.queue.consume(ctx, func(ctx context.Context, m types.Message) error {
+		event, err := s.unwrap(ctx, m)
+		if err != nil {
+			return err
+		}
+		return handler(ctx, event)
+	})
+}
+
+// unwrap returns the SNS envelope of the message, or an envelope built from a message delivered raw.
+func (s *Subscription) unwrap(ctx context.Context, m types.Message) (SNSEvent, error) {
+	body := aws.ToString(m.Body)
+
+	var probe envelopeProbe
+	if err := json.Unmarshal([]byte(body), &probe); err == nil && probe.isSNS() {
+		var event SNSEvent
+		if err := json.Unmarshal([]byte(body), &event); err != nil {
+			s.queue.logDecodeFailure(ctx, m, body, err)
+			return SNSEvent{}, DeadLetter(fmt.Errorf("json.Unmarshal: %w", err))
+		}
+		return event, nil
+	}
+
+	event := SNSEvent{
+		Type:      snsTypeNotification,
+		MessageId: aws.ToString(m.MessageId),
+		Message:   body,
+		TopicArn:  s.topic.topicArn,
+	}
+	if len(m.MessageAttributes) > 0 {
+		event.MessageAttributes = make(map[string]map[string]string, len(m.MessageAttributes))
+		for name, v := range m.MessageAttributes {
+			// Binary values are base64 encoded, as in the envelope.
+			value := aws.ToString(v.StringValue)
+			if base, _, _ := strings.Cut(aws.ToString(v.DataType), "."); base == AttributeDataTypeBinary {
+				value = base64.StdEncoding.EncodeToString(v.BinaryValue)
+			}
+			event.MessageAttributes[name] = map[string]string{
+				"Type":  aws.ToString(v.DataType),
+				"Value": value,
+			}
+		}
+	}
+
+	return event, nil
+}
+
+// FilterPolicy returns the filter policy of the subscription, nil if it has none.
+func (s *Subscription) FilterPolicy(ctx context.Context) (*FilterPolicy, error) {
+	a, err := s.Attributes(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("s.Attributes: %w", err)
+	}
+	if a.FilterPolicy == "" || a.FilterPolicy == emptyFilterPolicy {
+		return nil, nil
+	}
+
+	p, err := ParseFilterPolicy(a.FilterPolicy, a.FilterPolicyScope)
+	if err != nil {
+		return nil, fmt.Errorf("ParseFilterPolicy: %w", err)
+	}
+
+	return p, nil
+}
+
+// SetFilterPolicy replaces the filter policy of the subscription, or removes it if p is nil.
+func (s *Subscription) SetFilterPolicy(ctx context.Context, p *FilterPolicy) error {
+	opts := SubscriptionOptions{Filter: p}
+	if p == nil {
+		opts.Attributes = map[string]string{SubscriptionAttributeFilterPolicy: emptyFilterPolicy}
+	}
+	if err := s.SetAttributes(ctx, opts); err != nil {
+		return fmt.Errorf("s.SetAttributes: %w", err)
+	}
+
+	return nil
+}
+
+// RawMessageDelivery returns whether messages are delivered without the SNS envelope.
+func (s *Subscription) RawMessageDelivery(ctx context.Context) (bool, error) {
+	a, err := s.Attributes(ctx)
+	if err != nil {
+		return false, fmt.Errorf("s.Attributes: %w", err)
+	}
+
+	return a.RawMessageDelivery, nil
+}
+
+// SetRawMessageDelivery sets whether messages are delivered without the SNS envelope.
+// The Consume methods of the subscription handle both deliveries.
+func (s *Subscription) SetRawMessageDelivery(ctx context.Context, raw bool) error {
+	if err := s.SetAttributes(ctx, SubscriptionOptions{
+		Attributes: map[string]string{SubscriptionAttributeRawMessageDelivery: fmt.Sprint(raw)},
+	}); err != nil {
+		return fmt.Errorf("s.SetAttributes: %w", err)
+	}
+
+	return nil
+}
+
+// DeadLetterQueue returns the queue receiving messages that SNS fails to deliver, nil if there is none.
+func (s *Subscription) DeadLetterQueue(ctx context.Context) (*Queue, error) {
+	a, err := s.Attributes(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("s.Attributes: %w", err)
+	}
+	if a.DeadLetterTargetArn == "" {
+		return nil, nil
+	}
+
+	q, err := s.client.NewQueueContext(ctx, a.DeadLetterTargetArn)
+	if err != nil {
+		return nil, fmt.Errorf("s.client.NewQueueContext(%s) : %w", a.DeadLetterTargetArn, err)
+	}
+
+	return q, nil
+}
+
+// SetDeadLetterQueue sets the queue receiving messages that SNS fails to deliver, or removes it if q is nil.
+// The queue policy must allow the topic to send messages to it: grant merges that statement into it first,
+// as Queue.AllowTopic does.
+func (s *Subscription) SetDeadLetterQueue(ctx context.Context, q *Queue, grant bool) error {
+	opts := SubscriptionOptions{Attributes: map[string]string{SubscriptionAttributeRedrivePolicy: ""}}
+	if q != nil {
+		if grant {
+			if err := q.AllowTopic(ctx, &s.topic); err != nil {
+				return fmt.Errorf("q.AllowTopic: %w", err)
+			}
+		}
+		opts = SubscriptionOptions{DeadLetterTargetArn: q.queueArn}
+	}
+	if err := s.SetAttributes(ctx, opts); err != nil {
+		return fmt.Errorf("s.SetAttributes: %w", err)
+	}
+
+	return nil
+}
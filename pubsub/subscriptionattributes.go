@@ -0,0 +1,98 @@
+package pubsub
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/sns"
+)
+
+// SubscriptionAttributes is the configuration and state of a subscription.
+type SubscriptionAttributes struct {
+	SubscriptionArn   string
+	TopicArn          string
+	Protocol          string
+	Endpoint          string
+	Owner             string
+	FilterPolicy      string
+	FilterPolicyScope string
+	// RawMessageDelivery is whether messages are delivered without the SNS envelope.
+	RawMessageDelivery bool
+	// DeadLetterTargetArn is the queue receiving messages that SNS fails to deliver, if any.
+	DeadLetterTargetArn          string
+	DeliveryPolicy               string
+	EffectiveDeliveryPolicy      string
+	SubscriptionRoleArn          string
+	PendingConfirmation          bool
+	ConfirmationWasAuthenticated bool
+	// Raw are all the attributes as returned by AWS.
+	Raw map[string]string
+}
+
+// Attributes returns the configuration of the subscription.
+func (s *Subscription) Attributes(ctx context.Context) (*SubscriptionAttributes, error) {
+	output, err := s.client.SNS.GetSubscriptionAttributes(ctx, &sns.GetSubscriptionAttributesInput{
+		SubscriptionArn: &s.subscriptionArn,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("s.client.SNS.GetSubscriptionAttributes: %w", err)
+	}
+
+	p := attributeParser{attributes: output.Attributes}
+	a := &SubscriptionAttributes{
+		SubscriptionArn:              output.Attributes["SubscriptionArn"],
+		TopicArn:                     output.Attributes[NameTopicArn],
+		Protocol:                     output.Attributes["Protocol"],
+		Endpoint:                     output.Attributes["Endpoint"],
+		Owner:                        output.Attributes["Owner"],
+		FilterPolicy:                 output.Attributes[SubscriptionAttributeFilterPolicy],
+		FilterPolicyScope:            output.Attributes[SubscriptionAttributeFilterPolicyScope],
+		RawMessageDelivery:           p.bool(SubscriptionAttributeRawMessageDelivery),
+		DeliveryPolicy:               output.Attributes[SubscriptionAttributeDeliveryPolicy],
+		EffectiveDeliveryPolicy:      output.Attributes["EffectiveDeliveryPolicy"],
+		SubscriptionRoleArn:          output.Attributes[SubscriptionAttributeSubscriptionRoleArn],
+		PendingConfirmation:          p.bool("PendingConfirmation"),
+		ConfirmationWasAuthenticated: p.bool("ConfirmationWasAuthenticated"),
+		Raw:                          output.Attributes,
+	}
+	policy, err := parseRedrivePolicy(output.Attributes[SubscriptionAttributeRedrivePolicy])
+	if err != nil {
+		return nil, fmt.Errorf("parseRedrivePolicy: %w", err)
+	}
+	if policy != nil {
+		a.DeadLetterTargetArn = policy.DeadLetterTargetArn
+	}
+	if p.err != nil {
+		return nil, p.err
+	}
+
+	return a, nil
+}
+
+// SetAttributes validates the options and sets them on the subscription, one attribute per call as SNS requires.
+// GrantQueueAccess merges the statement allowing the topic into the queue policy first.
+// Zero fields are left unchanged; set a zero value, e.g. RawMessageDelivery false, through the Attributes of the options.
+func (s *Subscription) SetAttributes(ctx context.Context, opts SubscriptionOptions) error {
+	if err := opts.Validate(); err != nil {
+		return fmt.Errorf("opts.Validate: %w", err)
+	}
+	if opts.GrantQueueAccess {
+		if err := s.queue.AllowTopic(ctx, &s.topic); err != nil {
+			return fmt.Errorf("s.queue.AllowTopic: %w", err)
+		}
+	}
+
+	attributes := opts.attributes()
+	for _, name := range sortedKeys(attributes) {
+		if _, err := s.client.SNS.SetSubscriptionAttributes(ctx, &sns.SetSubscriptionAttributesInput{
+			SubscriptionArn: &s.subscriptionArn,
+			AttributeName:   aws.String(name),
+			AttributeValue:  aws.String(attributes[name]),
+		}); err != nil {
+			return fmt.Errorf("s.client.SNS.SetSubscriptionAttributes(%s) : %w", name, err)
+		}
+	}
+
+	return nil
+}
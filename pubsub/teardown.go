@@ -0,0 +1,163 @@
+package pubsub
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/aws/aws-sdk-go-v2/aws/arn"
+	"github.com/aws/aws-sdk-go-v2/service/sqs/types"
+)
+
+// TeardownOptions configures Teardown.
+type TeardownOptions struct {
+	// DeleteQueues deletes the subscribed queues. Queues that are also subscribed to other topics are kept.
+	DeleteQueues bool
+	// DeleteDeadLetterQueues deletes the dead-letter queues of the deleted queues.
+	// Dead-letter queues that are also used by other queues are kept.
+	DeleteDeadLetterQueues bool
+	// Force deletes queues that still have messages. Otherwise nothing is deleted if any of them has messages.
+	Force bool
+}
+
+// TeardownResult is the result of Teardown. The resources are identified by arn.
+type TeardownResult struct {
+	Topic         string
+	Unsubscribed  []string
+	DeletedQueues []string
+	// KeptQueues are the queues that were not deleted because they are still used outside the topic.
+	KeptQueues []string
+}
+
+// Teardown deletes the topic with its SQS subscriptions and, optionally, the subscribed queues and
+// their dead-letter queues. Every queue to delete is checked before anything is deleted, so that a
+// non-empty queue stops the teardown without leaving it half done. Subscriptions of queues that no
+// longer exist are removed too.
+func (t *Topic) Teardown(ctx context.Context, opts TeardownOptions) (*TeardownResult, error) {
+	var (
+		subscriptions []*Subscription
+		queues        []*Queue
+		deleting      = make(map[string]bool)
+		result        = &TeardownResult{Topic: t.topicArn}
+	)
+	it := t.Subscriptions()
+	for it.Next(ctx) {
+		s := it.Value()
+		if s.Protocol != *SubscriptionProtocolSQS {
+			continue
+		}
+		// The queue is only resolved to be deleted, so that subscriptions of deleted queues are still removed.
+		queueName := s.Endpoint
+		if parsed, err := arn.Parse(s.Endpoint); err == nil {
+			queueName = parsed.Resource
+		}
+		subscriptions = append(subscriptions, &Subscription{
+			client:          t.client,
+			subscriptionArn: s.SubscriptionArn,
+			topic:           *t,
+			queue:           Queue{client: t.client, queueArn: s.Endpoint, queueName: queueName},
+		})
+		if !opts.DeleteQueues || deleting[s.Endpoint] {
+			continue
+		}
+		queue, err := t.client.NewQueueContext(ctx, s.Endpoint)
+		if err != nil {
+			var notFound *types.QueueDoesNotExist
+			if errors.As(err, &notFound) {
+				continue
+			}
+			return nil, fmt.Errorf("t.client.NewQueueContext(%s) : %w", s.Endpoint, err)
+		}
+		queues = append(queues, queue)
+		deleting[queue.queueArn] = true
+	}
+	if err := it.Err(); err != nil {
+		return nil, fmt.Errorf("t.Subscriptions: %w", err)
+	}
+
+	if len(queues) > 0 {
+		all := t.client.ListSubscriptions()
+		for all.Next(ctx) {
+			s := all.Value()
+			if s.TopicArn != t.topicArn && deleting[s.Endpoint] {
+				delete(deleting, s.Endpoint)
+				result.KeptQueues = append(result.KeptQueues, s.Endpoint)
+			}
+		}
+		if err := all.Err(); err != nil {
+			return nil, fmt.Errorf("t.client.ListSubscriptions: %w", err)
+		}
+	}
+
+	var deadLetterQueues []*Queue
+	if opts.DeleteDeadLetterQueues {
+		for _, queue := range queues {
+			if !deleting[queue.queueArn] {
+				continue
+			}
+			dlqArn, err := queue.deadLetterTargetArn(ctx)
+			if err != nil {
+				return nil, fmt.Errorf("queue.deadLetterTargetArn(%s) : %w", queue.queueName, err)
+			}
+			if dlqArn == "" || deleting[dlqArn] || containsString(result.KeptQueues, dlqArn) {
+				continue
+			}
+			dlq, err := t.client.NewQueueContext(ctx, dlqArn)
+			if err != nil {
+				var notFound *types.QueueDoesNotExist
+				if errors.As(err, &notFound) {
+					continue
+				}
+				return nil, fmt.Errorf("t.client.NewQueueContext(%s) : %w", dlqArn, err)
+			}
+			deadLetterQueues = append(deadLetterQueues, dlq)
+			deleting[dlqArn] = true
+		}
+		for _, dlq := range deadLetterQueues {
+			sources, err := dlq.DeadLetterSourceQueues(ctx)
+			if err != nil {
+				return nil, fmt.Errorf("dlq.DeadLetterSourceQueues(%s) : %w", dlq.queueName, err)
+			}
+			for _, source := range sources {
+				if !deleting[source.queueArn] {
+					delete(deleting, dlq.queueArn)
+					result.KeptQueues = append(result.KeptQueues, dlq.queueArn)
+					break
+				}
+			}
+		}
+	}
+	queues = append(queues, deadLetterQueues...)
+
+	if !opts.Force {
+		for _, queue := range queues {
+			if !deleting[queue.queueArn] {
+				continue
+			}
+			if err := queue.checkEmpty(ctx); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	for _, s := range subscriptions {
+		if err := s.Unsubscribe(ctx); err != nil {
+			return result, fmt.Errorf("s.Unsubscribe(%s) : %w", s.subscriptionArn, err)
+		}
+		result.Unsubscribed = append(result.Unsubscribed, s.subscriptionArn)
+	}
+	for _, queue := range queues {
+		if !deleting[queue.queueArn] {
+			continue
+		}
+		if err := queue.Delete(ctx, true); err != nil {
+			return result, fmt.Errorf("queue.Delete(%s) : %w", queue.queueName, err)
+		}
+		result.DeletedQueues = append(result.DeletedQueues, queue.queueArn)
+	}
+	if err := t.Delete(ctx); err != nil {
+		return result, fmt.Errorf("t.Delete: %w", err)
+	}
+
+	return result, nil
+}
@@ -0,0 +1,85 @@
+package pubsub
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/sns"
+)
+
+// TopicAttributes is the configuration and state of a topic.
+type TopicAttributes struct {
+	TopicArn                  string
+	Owner                     string
+	DisplayName               string
+	Policy                    string
+	DeliveryPolicy            string
+	EffectiveDeliveryPolicy   string
+	KmsMasterKeyId            string
+	FifoTopic                 bool
+	ContentBasedDeduplication bool
+	SignatureVersion          int
+	TracingConfig             string
+
+	SubscriptionsConfirmed int64
+	SubscriptionsPending   int64
+	SubscriptionsDeleted   int64
+	// Raw are all the attributes as returned by AWS.
+	Raw map[string]string
+}
+
+// Attributes returns the configuration and the subscription counts of the topic.
+func (t *Topic) Attributes(ctx context.Context) (*TopicAttributes, error) {
+	output, err := t.client.SNS.GetTopicAttributes(ctx, &sns.GetTopicAttributesInput{
+		TopicArn: &t.topicArn,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("t.client.SNS.GetTopicAttributes: %w", err)
+	}
+
+	p := attributeParser{attributes: output.Attributes}
+	a := &TopicAttributes{
+		TopicArn:                  output.Attributes[NameTopicArn],
+		Owner:                     output.Attributes["Owner"],
+		DisplayName:               output.Attributes[TopicAttributeDisplayName],
+		Policy:                    output.Attributes[TopicAttributePolicy],
+		DeliveryPolicy:            output.Attributes[TopicAttributeDeliveryPolicy],
+		EffectiveDeliveryPolicy:   output.Attributes["EffectiveDeliveryPolicy"],
+		KmsMasterKeyId:            output.Attributes[TopicAttributeKmsMasterKeyId],
+		FifoTopic:                 p.bool(TopicAttributeFifoTopic),
+		ContentBasedDeduplication: p.bool(TopicAttributeContentBasedDeduplication),
+		SignatureVersion:          int(p.int64(TopicAttributeSignatureVersion)),
+		TracingConfig:             output.Attributes[TopicAttributeTracingConfig],
+		SubscriptionsConfirmed:    p.int64("SubscriptionsConfirmed"),
+		SubscriptionsPending:      p.int64("SubscriptionsPending"),
+		SubscriptionsDeleted:      p.int64("SubscriptionsDeleted"),
+		Raw:                       output.Attributes,
+	}
+	if p.err != nil {
+		return nil, p.err
+	}
+
+	return a, nil
+}
+
+// SetAttributes validates the options and sets them on the topic, one attribute per call as SNS requires.
+// Zero fields are left unchanged; set a zero value through the Attributes of the options.
+func (t *Topic) SetAttributes(ctx context.Context, opts TopicOptions) error {
+	if err := opts.validate(t.topicName, false); err != nil {
+		return fmt.Errorf("opts.validate: %w", err)
+	}
+
+	attributes := opts.attributes()
+	for _, name := range sortedKeys(attributes) {
+		if _, err := t.client.SNS.SetTopicAttributes(ctx, &sns.SetTopicAttributesInput{
+			TopicArn:       &t.topicArn,
+			AttributeName:  aws.String(name),
+			AttributeValue: aws.String(attributes[name]),
+		}); err != nil {
+			return fmt.Errorf("t.client.SNS.SetTopicAttributes(%s) : %w", name, err)
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,566 @@
+package pubsub
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/sns"
+	"github.com/aws/aws-sdk-go-v2/service/sqs"
+	"github.com/aws/aws-sdk-go-v2/service/sqs/types"
+	"gopkg.in/yaml.v3"
+)
+
+// defaultMaxReceiveCount is the maxReceiveCount of a queue with a dead-letter queue and no maxReceiveCount.
+const defaultMaxReceiveCount = 5
+
+// Topology declares topics, queues with their dead-letter queues, and subscriptions of queues to topics.
+type Topology struct {
+	Topics        []TopicSpec        `yaml:"topics,omitempty"`
+	Queues        []QueueSpec        `yaml:"queues,omitempty"`
+	Subscriptions []SubscriptionSpec `yaml:"subscriptions,omitempty"`
+}
+
+// TopicSpec declares a topic.
+type TopicSpec struct {
+	Name       string            `yaml:"name"`
+	Attributes map[string]string `yaml:"attributes,omitempty"`
+}
+
+// QueueSpec declares a queue. DeadLetterQueue is the name of another declared queue.
+type QueueSpec struct {
+	Name            string            `yaml:"name"`
+	Attributes      map[string]string `yaml:"attributes,omitempty"`
+	DeadLetterQueue string            `yaml:"deadLetterQueue,omitempty"`
+	// MaxReceiveCount defaults to 5 when DeadLetterQueue is set.
+	MaxReceiveCount int64 `yaml:"maxReceiveCount,omitempty"`
+}
+
+// SubscriptionSpec declares a subscription of a declared queue to a declared topic.
+// Creating it also merges a statement allowing the topic to send messages into the queue policy.
+type SubscriptionSpec struct {
+	Topic      string            `yaml:"topic"`
+	Queue      string            `yaml:"queue"`
+	Attributes map[string]string `yaml:"attributes,omitempty"`
+}
+
+// name returns the name of the subscription used in plans, e.g. orders->orders-worker.
+func (s SubscriptionSpec) name() string {
+	return s.Topic + "->" + s.Queue
+}
+
+// ParseTopology parses and validates a YAML topology document.
+func ParseTopology(b []byte) (*Topology, error) {
+	var t Topology
+	if err := yaml.Unmarshal(b, &t); err != nil {
+		return nil, fmt.Errorf("yaml.Unmarshal: %w", err)
+	}
+	if err := t.Validate(); err != nil {
+		return nil, fmt.Errorf("t.Validate: %w", err)
+	}
+
+	return &t, nil
+}
+
+// Validate checks that names are unique and that references point to declared resources without cycles.
+func (t *Topology) Validate() error {
+	topics := make(map[string]bool, len(t.Topics))
+	for _, topic := range t.Topics {
+		if topic.Name == "" {
+			return errors.New("topic name must not be empty")
+		}
+		if topics[topic.Name] {
+			return fmt.Errorf("topic %s is declared twice", topic.Name)
+		}
+		topics[topic.Name] = true
+	}
+
+	queues := make(map[string]QueueSpec, len(t.Queues))
+	for _, queue := range t.Queues {
+		if queue.Name == "" {
+			return errors.New("queue name must not be empty")
+		}
+		if _, ok := queues[queue.Name]; ok {
+			return fmt.Errorf("queue %s is declared twice", queue.Name)
+		}
+		if _, ok := queue.Attributes[QueueAttributeRedrivePolicy]; ok && queue.DeadLetterQueue != "" {
+			return fmt.Errorf("queue %s sets both deadLetterQueue and the %s attribute", queue.Name, QueueAttributeRedrivePolicy)
+		}
+		queues[queue.Name] = queue
+	}
+	for _, queue := range t.Queues {
+		seen := map[string]bool{queue.Name: true}
+		for dlq := queue.DeadLetterQueue; dlq != ""; dlq = queues[dlq].DeadLetterQueue {
+			if _, ok := queues[dlq]; !ok {
+				return fmt.Errorf("queue %s: dead-letter queue %s is not declared", queue.Name, dlq)
+			}
+			if seen[dlq] {
+				return fmt.Errorf("queue %s: dead-letter queues form a cycle", queue.Name)
+			}
+			seen[dlq] = true
+		}
+	}
+
+	subscriptions := make(map[string]bool, len(t.Subscriptions))
+	for _, s := range t.Subscriptions {
+		if !topics[s.Topic] {
+			return fmt.Errorf("subscription %s: topic %s is not declared", s.name(), s.Topic)
+		}
+		if _, ok := queues[s.Queue]; !ok {
+			return fmt.Errorf("subscription %s: queue %s is not declared", s.name(), s.Queue)
+		}
+		if subscriptions[s.name()] {
+			return fmt.Errorf("subscription %s is declared twice", s.name())
+		}
+		subscriptions[s.name()] = true
+	}
+
+	return nil
+}
+
+// orderedQueues returns the queues with every dead-letter queue before the queues that use it.
+func (t *Topology) orderedQueues() []QueueSpec {
+	var (
+		ordered []QueueSpec
+		done    = make(map[string]bool, len(t.Queues))
+	)
+	for len(ordered) < len(t.Queues) {
+		for _, queue := range t.Queues {
+			if !done[queue.Name] && (queue.DeadLetterQueue == "" || done[queue.DeadLetterQueue]) {
+				ordered = append(ordered, queue)
+				done[queue.Name] = true
+			}
+		}
+	}
+
+	return ordered
+}
+
+// ResourceKind is the kind of resource in a topology.
+type ResourceKind string
+
+const (
+	ResourceTopic        = ResourceKind("topic")
+	ResourceQueue        = ResourceKind("queue")
+	ResourceSubscription = ResourceKind("subscription")
+)
+
+// ChangeAction is what applying a change does.
+type ChangeAction string
+
+const (
+	ChangeCreate = ChangeAction("create")
+	ChangeUpdate = ChangeAction("update")
+)
+
+// AttributeChange is a changed attribute. Old is empty for new attributes.
+type AttributeChange struct {
+	Name string
+	Old  string
+	New  string
+}
+
+// Change is a resource that applying the plan creates or updates.
+type Change struct {
+	Kind       ResourceKind
+	Name       string
+	Action     ChangeAction
+	Attributes []AttributeChange
+}
+
+// Plan is the list of changes that make the account match a topology.
+type Plan struct {
+	Changes []Change
+
+	topology      *Topology
+	topics        map[string]*Topic
+	queues        map[string]*Queue
+	subscriptions map[string]string
+}
+
+// Empty returns whether the account already matches the topology.
+func (p *Plan) Empty() bool {
+	return len(p.Changes) == 0
+}
+
+// String returns the changes, one per line, with + for creations and ~ for updates.
+func (p *Plan) String() string {
+	if p.Empty() {
+		return "no changes\n"
+	}
+
+	var b strings.Builder
+	for _, c := range p.Changes {
+		sign := "~"
+		if c.Action == ChangeCreate {
+			sign = "+"
+		}
+		fmt.Fprintf(&b, "%s %s %s\n", sign, c.Kind, c.Name)
+		for _, a := range c.Attributes {
+			if c.Action == ChangeCreate {
+				fmt.Fprintf(&b, "    %s: %s\n", a.Name, a.New)
+			} else {
+				fmt.Fprintf(&b, "    %s: %s -> %s\n", a.Name, a.Old, a.New)
+			}
+		}
+	}
+
+	return b.String()
+}
+
+// PlanTopology compares the topology with the account and returns the changes that ApplyTopology would make.
+func (c *PubsubClient) PlanTopology(ctx context.Context, t *Topology) (*Plan, error) {
+	if err := t.Validate(); err != nil {
+		return nil, fmt.Errorf("t.Validate: %w", err)
+	}
+
+	p := &Plan{
+		topology:      t,
+		topics:        make(map[string]*Topic),
+		queues:        make(map[string]*Queue),
+		subscriptions: make(map[string]string),
+	}
+
+	topicArns, err := c.topicArnsByName(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("c.topicArnsByName: %w", err)
+	}
+	for _, spec := range t.Topics {
+		topicArn, ok := topicArns[spec.Name]
+		if !ok {
+			p.Changes = append(p.Changes, Change{Kind: ResourceTopic, Name: spec.Name, Action: ChangeCreate, Attributes: attributeChanges(nil, spec.Attributes)})
+			continue
+		}
+		p.topics[spec.Name] = &Topic{client: c, topicName: spec.Name, topicArn: topicArn}
+
+		output, err := c.SNS.GetTopicAttributes(ctx, &sns.GetTopicAttributesInput{TopicArn: aws.String(topicArn)})
+		if err != nil {
+			return nil, fmt.Errorf("c.SNS.GetTopicAttributes(%s) : %w", topicArn, err)
+		}
+		if changes := attributeChanges(output.Attributes, spec.Attributes); len(changes) > 0 {
+			p.Changes = append(p.Changes, Change{Kind: ResourceTopic, Name: spec.Name, Action: ChangeUpdate, Attributes: changes})
+		}
+	}
+
+	for _, spec := range t.orderedQueues() {
+		desired := copyAttributes(spec.Attributes)
+		if spec.DeadLetterQueue != "" {
+			desired[QueueAttributeRedrivePolicy] = p.redrivePolicy(spec)
+		}
+
+		queue, err := c.findQueue(ctx, spec.Name)
+		if err != nil {
+			return nil, fmt.Errorf("c.findQueue(%s) : %w", spec.Name, err)
+		}
+		if queue == nil {
+			p.Changes = append(p.Changes, Change{Kind: ResourceQueue, Name: spec.Name, Action: ChangeCreate, Attributes: attributeChanges(nil, desired)})
+			continue
+		}
+		p.queues[spec.Name] = queue
+
+		output, err := c.SQS.GetQueueAttributes(ctx, &sqs.GetQueueAttributesInput{
+			AttributeNames: []types.QueueAttributeName{types.QueueAttributeNameAll},
+			QueueUrl:       aws.String(queue.queueUrl),
+		})
+		if err != nil {
+			return nil, fmt.Errorf("c.SQS.GetQueueAttributes(%s) : %w", queue.queueUrl, err)
+		}
+		if changes := attributeChanges(output.Attributes, desired); len(changes) > 0 {
+			p.Changes = append(p.Changes, Change{Kind: ResourceQueue, Name: spec.Name, Action: ChangeUpdate, Attributes: changes})
+		}
+	}
+
+	for _, spec := range t.Subscriptions {
+		topic, queue := p.topics[spec.Topic], p.queues[spec.Queue]
+		if topic != nil && queue != nil {
+			subscriptionArn, err := c.findSubscription(ctx, topic, queue)
+			if err != nil {
+				return nil, fmt.Errorf("c.findSubscription(%s) : %w", spec.name(), err)
+			}
+			if subscriptionArn != "" {
+				p.subscriptions[spec.name()] = subscriptionArn
+
+				output, err := c.SNS.GetSubscriptionAttributes(ctx, &sns.GetSubscriptionAttributesInput{SubscriptionArn: aws.String(subscriptionArn)})
+				if err != nil {
+					return nil, fmt.Errorf("c.SNS.GetSubscriptionAttributes(%s) : %w", subscriptionArn, err)
+				}
+				if changes := attributeChanges(output.Attributes, spec.Attributes); len(changes) > 0 {
+					p.Changes = append(p.Changes, Change{Kind: ResourceSubscription, Name: spec.name(), Action: ChangeUpdate, Attributes: changes})
+				}
+				continue
+			}
+		}
+		p.Changes = append(p.Changes, Change{Kind: ResourceSubscription, Name: spec.name(), Action: ChangeCreate, Attributes: attributeChanges(nil, spec.Attributes)})
+	}
+
+	return p, nil
+}
+
+// ApplyTopology makes the account match the topology and returns the applied plan.
+// Resources that already match are left untouched, so applying the same topology again makes no changes.
+func (c *PubsubClient) ApplyTopology(ctx context.Context, t *Topology) (*Plan, error) {
+	p, err := c.PlanTopology(ctx, t)
+	if err != nil {
+		return nil, fmt.Errorf("c.PlanTopology: %w", err)
+	}
+	if err := c.ApplyPlan(ctx, p); err != nil {
+		return p, fmt.Errorf("c.ApplyPlan: %w", err)
+	}
+
+	return p, nil
+}
+
+// ApplyPlan makes the changes of a plan returned by PlanTopology, in order.
+func (c *PubsubClient) ApplyPlan(ctx context.Context, p *Plan) error {
+	specs := make(map[ResourceKind]map[string]map[string]string)
+	specs[ResourceTopic] = make(map[string]map[string]string)
+	specs[ResourceQueue] = make(map[string]map[string]string)
+	specs[ResourceSubscription] = make(map[string]map[string]string)
+	queueSpecs := make(map[string]QueueSpec)
+	subscriptionSpecs := make(map[string]SubscriptionSpec)
+	for _, s := range p.topology.Topics {
+		specs[ResourceTopic][s.Name] = s.Attributes
+	}
+	for _, s := range p.topology.Queues {
+		specs[ResourceQueue][s.Name] = s.Attributes
+		queueSpecs[s.Name] = s
+	}
+	for _, s := range p.topology.Subscriptions {
+		specs[ResourceSubscription][s.name()] = s.Attributes
+		subscriptionSpecs[s.name()] = s
+	}
+
+	for _, change := range p.Changes {
+		if err := c.applyChange(ctx, p, change, specs[change.Kind][change.Name], queueSpecs, subscriptionSpecs); err != nil {
+			return fmt.Errorf("%s %s %s: %w", change.Action, change.Kind, change.Name, err)
+		}
+	}
+
+	return nil
+}
+
+// applyChange makes a single change of the plan and records the created resources in it.
+func (c *PubsubClient) applyChange(ctx context.Context, p *Plan, change Change, attributes map[string]string, queueSpecs map[string]QueueSpec, subscriptionSpecs map[string]SubscriptionSpec) error {
+	switch change.Kind {
+	case ResourceTopic:
+		if change.Action == ChangeCreate {
+			topic, err := c.CreateTopicContext(ctx, change.Name, toOpts(attributes))
+			if err != nil {
+				return fmt.Errorf("c.CreateTopicContext: %w", err)
+			}
+			p.topics[change.Name] = topic
+			return nil
+		}
+		for _, a := range change.Attributes {
+			if _, err := c.SNS.SetTopicAttributes(ctx, &sns.SetTopicAttributesInput{
+				TopicArn:       aws.String(p.topics[change.Name].topicArn),
+				AttributeName:  aws.String(a.Name),
+				AttributeValue: aws.String(a.New),
+			}); err != nil {
+				return fmt.Errorf("c.SNS.SetTopicAttributes(%s) : %w", a.Name, err)
+			}
+		}
+	case ResourceQueue:
+		spec := queueSpecs[change.Name]
+		if change.Action == ChangeCreate {
+			var (
+				queue *Queue
+				err   error
+			)
+			if spec.DeadLetterQueue != "" {
+				queue, err = c.CreateQueueWithDLQContext(ctx, spec.Name, *p.queues[spec.DeadLetterQueue], spec.maxReceiveCount(), toOpts(attributes))
+			} else {
+				queue, err = c.CreateQueueContext(ctx, spec.Name, toOpts(attributes))
+			}
+			if err != nil {
+				return fmt.Errorf("c.CreateQueueContext: %w", err)
+			}
+			p.queues[change.Name] = queue
+			return nil
+		}
+		desired := make(map[string]string, len(change.Attributes))
+		for _, a := range change.Attributes {
+			desired[a.Name] = a.New
+		}
+		if spec.DeadLetterQueue != "" {
+			if _, ok := desired[QueueAttributeRedrivePolicy]; ok {
+				desired[QueueAttributeRedrivePolicy] = redrivePolicy(p.queues[spec.DeadLetterQueue].queueArn, spec.maxReceiveCount())
+			}
+		}
+		if _, err := c.SQS.SetQueueAttributes(ctx, &sqs.SetQueueAttributesInput{
+			QueueUrl:   aws.String(p.queues[change.Name].queueUrl),
+			Attributes: desired,
+		}); err != nil {
+			return fmt.Errorf("c.SQS.SetQueueAttributes: %w", err)
+		}
+	case ResourceSubscription:
+		if change.Action == ChangeCreate {
+			spec := subscriptionSpecs[change.Name]
+			subscription, err := c.CreateSubscriptionWithOptionsContext(ctx, p.topics[spec.Topic], p.queues[spec.Queue], SubscriptionOptions{
+				Attributes:       attributes,
+				GrantQueueAccess: true,
+			})
+			if err != nil {
+				return fmt.Errorf("c.CreateSubscriptionWithOptionsContext: %w", err)
+			}
+			p.subscriptions[change.Name] = subscription.subscriptionArn
+			return nil
+		}
+		for _, a := range change.Attributes {
+			if _, err := c.SNS.SetSubscriptionAttributes(ctx, &sns.SetSubscriptionAttributesInput{
+				SubscriptionArn: aws.String(p.subscriptions[change.Name]),
+				AttributeName:   aws.String(a.Name),
+				AttributeValue:  aws.String(a.New),
+			}); err != nil {
+				return fmt.Errorf("c.SNS.SetSubscriptionAttributes(%s) : %w", a.Name, err)
+			}
+		}
+	}
+
+	return nil
+}
+
+// maxReceiveCount returns the maxReceiveCount of the redrive policy.
+func (s QueueSpec) maxReceiveCount() int64 {
+	if s.MaxReceiveCount <= 0 {
+		return defaultMaxReceiveCount
+	}
+
+	return s.MaxReceiveCount
+}
+
+// redrivePolicy returns the desired RedrivePolicy of the queue, with a placeholder arn if the dead-letter queue
+// does not exist yet.
+func (p *Plan) redrivePolicy(spec QueueSpec) string {
+	deadLetterTargetArn := "(known after apply)"
+	if dlq, ok := p.queues[spec.DeadLetterQueue]; ok {
+		deadLetterTargetArn = dlq.queueArn
+	}
+
+	return redrivePolicy(deadLetterTargetArn, spec.maxReceiveCount())
+}
+
+// topicArnsByName returns the arns of every topic by topic name.
+func (c *PubsubClient) topicArnsByName(ctx context.Context) (map[string]string, error) {
+	arns := make(map[string]string)
+	it := c.ListTopics()
+	for it.Next(ctx) {
+		arns[it.Value().topicName] = it.Value().topicArn
+	}
+	if err := it.Err(); err != nil {
+		return nil, fmt.Errorf("c.ListTopics: %w", err)
+	}
+
+	return arns, nil
+}
+
+// findQueue returns the queue with the name, or nil if it does not exist.
+func (c *PubsubClient) findQueue(ctx context.Context, queueName string) (*Queue, error) {
+	output, err := c.SQS.GetQueueUrl(ctx, &sqs.GetQueueUrlInput{QueueName: aws.String(queueName)})
+	if err != nil {
+		var notFound *types.QueueDoesNotExist
+		if errors.As(err, &notFound) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("c.SQS.GetQueueUrl: %w", err)
+	}
+
+	queue, err := c.newQueueFromUrl(ctx, aws.ToString(output.QueueUrl))
+	if err != nil {
+		return nil, fmt.Errorf("c.newQueueFromUrl: %w", err)
+	}
+
+	return queue, nil
+}
+
+// findSubscription returns the arn of the subscription of the queue to the topic, or "" if it does not exist.
+func (c *PubsubClient) findSubscription(ctx context.Context, topic *Topic, queue *Queue) (string, error) {
+	it := topic.Subscriptions()
+	for it.Next(ctx) {
+		if s := it.Value(); s.Protocol == *SubscriptionProtocolSQS && s.Endpoint == queue.queueArn {
+			return s.SubscriptionArn, nil
+		}
+	}
+	if err := it.Err(); err != nil {
+		return "", fmt.Errorf("topic.Subscriptions: %w", err)
+	}
+
+	return "", nil
+}
+
+// attributeChanges returns the desired attributes that differ from the actual ones, sorted by name.
+// Values that are both JSON documents, such as policies, are compared by content.
+func attributeChanges(actual, desired map[string]string) []AttributeChange {
+	var changes []AttributeChange
+	for name, value := range desired {
+		old, ok := actual[name]
+		if ok && equalAttributeValues(old, value) {
+			continue
+		}
+		changes = append(changes, AttributeChange{Name: name, Old: old, New: value})
+	}
+	sort.Slice(changes, func(i, j int) bool { return changes[i].Name < changes[j].Name })
+
+	return changes
+}
+
+// equalAttributeValues returns whether two attribute values are equal, comparing JSON documents by content.
+// Numbers in JSON documents are compared as strings, since AWS returns some of them quoted, e.g. maxReceiveCount.
+func equalAttributeValues(a, b string) bool {
+	if a == b {
+		return true
+	}
+
+	var va, vb interface{}
+	if json.Unmarshal([]byte(a), &va) != nil || json.Unmarshal([]byte(b), &vb) != nil {
+		return false
+	}
+
+	return reflect.DeepEqual(normalizeJSON(va), normalizeJSON(vb))
+}
+
+// normalizeJSON converts the numbers of a decoded JSON document to strings.
+func normalizeJSON(v interface{}) interface{} {
+	switch v := v.(type) {
+	case map[string]interface{}:
+		for k, e := range v {
+			v[k] = normalizeJSON(e)
+		}
+		return v
+	case []interface{}:
+		for i, e := range v {
+			v[i] = normalizeJSON(e)
+		}
+		return v
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64)
+	}
+
+	return v
+}
+
+// copyAttributes returns a copy of the attributes that is never nil.
+func copyAttributes(in map[string]string) map[string]string {
+	out := make(map[string]string, len(in)+1)
+	for k, v := range in {
+		out[k] = v
+	}
+
+	return out
+}
+
+// toOpts converts attributes to the format of the Create methods.
+func toOpts(attributes map[string]string) map[string]*string {
+	opts := make(map[string]*string, len(attributes))
+	for k, v := range attributes {
+		opts[k] = aws.String(v)
+	}
+
+	return opts
+}
@@ -0,0 +1,210 @@
+package pubsub
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	snstypes "github.com/aws/aws-sdk-go-v2/service/sns/types"
+	sqstypes "github.com/aws/aws-sdk-go-v2/service/sqs/types"
+)
+
+const (
+	// AttributeTraceparent is the message attribute carrying the W3C traceparent header.
+	AttributeTraceparent = "traceparent"
+	// AttributeTracestate is the message attribute carrying the W3C tracestate header.
+	AttributeTracestate = "tracestate"
+
+	traceparentVersion = "00"
+	traceFlagSampled   = 0x01
+)
+
+// SpanContext is the W3C trace context of a span.
+type SpanContext struct {
+	TraceID    [16]byte
+	SpanID     [8]byte
+	TraceFlags byte
+	TraceState string
+	// Remote is true when the span context was extracted from a received message.
+	Remote bool
+}
+
+// NewSpanContext returns a sampled span context with a random trace id and span id.
+func NewSpanContext() (SpanContext, error) {
+	var sc SpanContext
+	if _, err := rand.Read(sc.TraceID[:]); err != nil {
+		return SpanContext{}, fmt.Errorf("rand.Read: %w", err)
+	}
+	if _, err := rand.Read(sc.SpanID[:]); err != nil {
+		return SpanContext{}, fmt.Errorf("rand.Read: %w", err)
+	}
+	sc.TraceFlags = traceFlagSampled
+
+	return sc, nil
+}
+
+// Child returns a span context in the same trace with a random span id.
+func (sc SpanContext) Child() (SpanContext, error) {
+	child := SpanContext{TraceID: sc.TraceID, TraceFlags: sc.TraceFlags, TraceState: sc.TraceState}
+	if _, err := rand.Read(child.SpanID[:]); err != nil {
+		return SpanContext{}, fmt.Errorf("rand.Read: %w", err)
+	}
+
+	return child, nil
+}
+
+// IsValid returns whether both the trace id and the span id are non-zero.
+func (sc SpanContext) IsValid() bool {
+	return sc.TraceID != [16]byte{} && sc.SpanID != [8]byte{}
+}
+
+// IsSampled returns whether the sampled flag is set.
+func (sc SpanContext) IsSampled() bool {
+	return sc.TraceFlags&traceFlagSampled != 0
+}
+
+// Traceparent returns the traceparent header value, e.g. 00-<trace id>-<span id>-01.
+func (sc SpanContext) Traceparent() string {
+	return fmt.Sprintf("%s-%s-%s-%02x", traceparentVersion, hex.EncodeToString(sc.TraceID[:]), hex.EncodeToString(sc.SpanID[:]), sc.TraceFlags)
+}
+
+// ParseTraceparent parses a traceparent header value and a tracestate header value.
+func ParseTraceparent(traceparent, tracestate string) (SpanContext, error) {
+	parts := strings.Split(strings.TrimSpace(traceparent), "-")
+	if len(parts) < 4 || len(parts[0]) != 2 || len(parts[1]) != 32 || len(parts[2]) != 16 || len(parts[3]) != 2 {
+		return SpanContext{}, fmt.Errorf("invalid traceparent %q", traceparent)
+	}
+	if parts[0] == "ff" || parts[0] == traceparentVersion && len(parts) != 4 {
+		return SpanContext{}, fmt.Errorf("invalid traceparent %q", traceparent)
+	}
+
+	var (
+		sc    SpanContext
+		flags [1]byte
+	)
+	if _, err := hex.Decode(sc.TraceID[:], []byte(parts[1])); err != nil {
+		return SpanContext{}, fmt.Errorf("hex.Decode(trace id) : %w", err)
+	}
+	if _, err := hex.Decode(sc.SpanID[:], []byte(parts[2])); err != nil {
+		return SpanContext{}, fmt.Errorf("hex.Decode(span id) : %w", err)
+	}
+	if _, err := hex.Decode(flags[:], []byte(parts[3])); err != nil {
+		return SpanContext{}, fmt.Errorf("hex.Decode(trace flags) : %w", err)
+	}
+	if !sc.IsValid() {
+		return SpanContext{}, errors.New("traceparent has a zero trace id or span id")
+	}
+	sc.TraceFlags = flags[0]
+	sc.TraceState = tracestate
+
+	return sc, nil
+}
+
+// spanContextKey is the context key of the SpanContext.
+type spanContextKey struct{}
+
+// ContextWithSpanContext returns a copy of ctx carrying the span context.
+// Send and Publish inject the span context of their context into the message attributes.
+func ContextWithSpanContext(ctx context.Context, sc SpanContext) context.Context {
+	return context.WithValue(ctx, spanContextKey{}, sc)
+}
+
+// SpanContextFromContext returns the span context carried by ctx.
+// In a handler, it is the span context extracted from the received message.
+func SpanContextFromContext(ctx context.Context) (SpanContext, bool) {
+	sc, ok := ctx.Value(spanContextKey{}).(SpanContext)
+	return sc, ok && sc.IsValid()
+}
+
+// traceAttributes returns the attribute values to inject for the span context of ctx.
+func traceAttributes(ctx context.Context) (traceparent, tracestate string, ok bool) {
+	sc, ok := SpanContextFromContext(ctx)
+	if !ok {
+		return "", "", false
+	}
+
+	return sc.Traceparent(), sc.TraceState, true
+}
+
+// injectSQSTrace returns a copy of the attributes with the trace context of ctx added.
+// The attributes are returned unchanged if ctx has no span context or there is no room for the trace attributes.
+func injectSQSTrace(ctx context.Context, attributes map[string]sqstypes.MessageAttributeValue) map[string]sqstypes.MessageAttributeValue {
+	traceparent, tracestate, ok := traceAttributes(ctx)
+	if !ok || len(attributes)+traceAttributeCount(tracestate) > MaxMessageAttributes {
+		return attributes
+	}
+
+	out := make(map[string]sqstypes.MessageAttributeValue, len(attributes)+2)
+	for k, v := range attributes {
+		out[k] = v
+	}
+	out[AttributeTraceparent] = sqstypes.MessageAttributeValue{DataType: aws.String(AttributeDataTypeString), StringValue: aws.String(traceparent)}
+	if tracestate != "" {
+		out[AttributeTracestate] = sqstypes.MessageAttributeValue{DataType: aws.String(AttributeDataTypeString), StringValue: aws.String(tracestate)}
+	}
+
+	return out
+}
+
+// injectSNSTrace returns a copy of the attributes with the trace context of ctx added.
+// The attributes are returned unchanged if ctx has no span context or there is no room for the trace attributes.
+func injectSNSTrace(ctx context.Context, attributes map[string]snstypes.MessageAttributeValue) map[string]snstypes.MessageAttributeValue {
+	traceparent, tracestate, ok := traceAttributes(ctx)
+	if !ok || len(attributes)+traceAttributeCount(tracestate) > MaxMessageAttributes {
+		return attributes
+	}
+
+	out := make(map[string]snstypes.MessageAttributeValue, len(attributes)+2)
+	for k, v := range attributes {
+		out[k] = v
+	}
+	out[AttributeTraceparent] = snstypes.MessageAttributeValue{DataType: aws.String(AttributeDataTypeString), StringValue: aws.String(traceparent)}
+	if tracestate != "" {
+		out[AttributeTracestate] = snstypes.MessageAttributeValue{DataType: aws.String(AttributeDataTypeString), StringValue: aws.String(tracestate)}
+	}
+
+	return out
+}
+
+// traceAttributeCount returns the number of attributes used by the trace context.
+func traceAttributeCount(tracestate string) int {
+	if tracestate == "" {
+		return 1
+	}
+
+	return 2
+}
+
+// extractTrace returns a copy of ctx carrying the span context of the message.
+// The trace context is read from the message attributes, or from the MessageAttributes of the SNS envelope in the body.
+func extractTrace(ctx context.Context, m sqstypes.Message) context.Context {
+	traceparent := aws.ToString(m.MessageAttributes[AttributeTraceparent].StringValue)
+	tracestate := aws.ToString(m.MessageAttributes[AttributeTracestate].StringValue)
+
+	if traceparent == "" && m.Body != nil && strings.HasPrefix(strings.TrimSpace(*m.Body), "{") {
+		var envelope struct {
+			Type              string
+			MessageAttributes map[string]map[string]string
+		}
+		if err := json.Unmarshal([]byte(*m.Body), &envelope); err == nil && envelope.Type == snsTypeNotification {
+			traceparent = envelope.MessageAttributes[AttributeTraceparent]["Value"]
+			tracestate = envelope.MessageAttributes[AttributeTracestate]["Value"]
+		}
+	}
+	if traceparent == "" {
+		return ctx
+	}
+
+	sc, err := ParseTraceparent(traceparent, tracestate)
+	if err != nil {
+		return ctx
+	}
+	sc.Remote = true
+
+	return ContextWithSpanContext(ctx, sc)
+}
@@ -0,0 +1,157 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+
+	"github.com/sonkibon/go-samples/pubsub"
+	"gopkg.in/yaml.v3"
+)
+
+// readTopology parses the -file flag and reads the topology file.
+func readTopology(name string, args []string) (*pubsub.Topology, error) {
+	fs := flag.NewFlagSet(name, flag.ExitOnError)
+	file := fs.String("file", "", "topology YAML file")
+	if err := parse(fs, args, "file"); err != nil {
+		return nil, err
+	}
+
+	return readTopologyFile(*file)
+}
+
+// readTopologyFile reads and parses a topology file.
+func readTopologyFile(file string) (*pubsub.Topology, error) {
+	b, err := os.ReadFile(file)
+	if err != nil {
+		return nil, fmt.Errorf("os.ReadFile: %w", err)
+	}
+	t, err := pubsub.ParseTopology(b)
+	if err != nil {
+		return nil, fmt.Errorf("pubsub.ParseTopology(%s) : %w", file, err)
+	}
+
+	return t, nil
+}
+
+// printPlan writes the changes of the plan.
+func printPlan(out *printer, p *pubsub.Plan) error {
+	if out.json {
+		return out.print(p.Changes, nil, nil)
+	}
+
+	_, err := fmt.Fprint(out.w, p.String())
+	return err
+}
+
+// plan shows the changes that apply would make.
+func plan(ctx context.Context, e *env, args []string) error {
+	t, err := readTopology("plan", args)
+	if err != nil {
+		return err
+	}
+
+	p, err := e.client.PlanTopology(ctx, t)
+	if err != nil {
+		return fmt.Errorf("e.client.PlanTopology: %w", err)
+	}
+
+	return printPlan(e.out, p)
+}
+
+// apply creates and updates the resources of a topology file.
+func apply(ctx context.Context, e *env, args []string) error {
+	t, err := readTopology("apply", args)
+	if err != nil {
+		return err
+	}
+
+	p, err := e.client.ApplyTopology(ctx, t)
+	if err != nil {
+		return fmt.Errorf("e.client.ApplyTopology: %w", err)
+	}
+
+	return printPlan(e.out, p)
+}
+
+// drift compares the topology reachable from a topic or queue with a topology file.
+func drift(ctx context.Context, e *env, args []string) error {
+	fs := flag.NewFlagSet("drift", flag.ExitOnError)
+	resourceArn := fs.String("arn", "", "ARN of a topic or queue to crawl from")
+	file := fs.String("file", "", "topology YAML file")
+	if err := parse(fs, args, "arn", "file"); err != nil {
+		return err
+	}
+
+	declared, err := readTopologyFile(*file)
+	if err != nil {
+		return err
+	}
+	drifts, err := e.client.DetectDrift(ctx, declared, *resourceArn)
+	if err != nil {
+		return fmt.Errorf("e.client.DetectDrift: %w", err)
+	}
+
+	rows := make([][]string, 0, len(drifts))
+	for _, d := range drifts {
+		if len(d.Attributes) == 0 {
+			rows = append(rows, []string{string(d.Type), string(d.Kind), d.Name, "", "", ""})
+		}
+		for _, a := range d.Attributes {
+			rows = append(rows, []string{string(d.Type), string(d.Kind), d.Name, a.Name, a.Old, a.New})
+		}
+	}
+
+	return e.out.print(drifts, []string{"DRIFT", "KIND", "NAME", "ATTRIBUTE", "ACTUAL", "DECLARED"}, rows)
+}
+
+// graph writes the topology reachable from a topic or queue, or the topology of a file, as DOT, Mermaid or YAML.
+func graph(ctx context.Context, e *env, args []string) error {
+	fs := flag.NewFlagSet("graph", flag.ExitOnError)
+	resourceArn := fs.String("arn", "", "ARN of a topic or queue to crawl from")
+	file := fs.String("file", "", "topology YAML file")
+	format := fs.String("format", "dot", "graph format: dot, mermaid or yaml")
+	if err := parse(fs, args); err != nil {
+		return err
+	}
+
+	var (
+		t   *pubsub.Topology
+		err error
+	)
+	switch {
+	case *resourceArn != "":
+		t, err = e.client.CrawlTopology(ctx, *resourceArn)
+		if err != nil {
+			return fmt.Errorf("e.client.CrawlTopology: %w", err)
+		}
+	case *file != "":
+		t, err = readTopologyFile(*file)
+		if err != nil {
+			return err
+		}
+	default:
+		return errors.New("-arn or -file is required")
+	}
+
+	var out string
+	switch *format {
+	case "dot":
+		out = t.DOT()
+	case "mermaid":
+		out = t.Mermaid()
+	case "yaml":
+		b, err := yaml.Marshal(t)
+		if err != nil {
+			return fmt.Errorf("yaml.Marshal: %w", err)
+		}
+		out = string(b)
+	default:
+		return fmt.Errorf("unknown graph format: %s", *format)
+	}
+
+	_, err = fmt.Fprint(e.out.w, out)
+	return err
+}